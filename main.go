@@ -94,6 +94,11 @@ func main() {
 		}
 		return
 	}
+	if options.Remote != nil {
+		code, err := fzf.RunRemote(options)
+		exit(code, err)
+		return
+	}
 	if options.Man {
 		file := fzf.WriteTemporaryFile([]string{string(manPage)}, "\n")
 		if len(file) == 0 {
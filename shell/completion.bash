@@ -140,6 +140,7 @@ _fzf_opts_completion() {
     --footer-border
     --footer-label
     --footer-label-pos
+    --frecency
     --freeze-left
     --freeze-right
     --gap
@@ -169,6 +170,7 @@ _fzf_opts_completion() {
     --keep-right
     --layout
     --listen
+    --listen-actions
     --listen-unsafe
     --list-border
     --list-label
@@ -197,11 +199,14 @@ _fzf_opts_completion() {
     --prompt
     --raw
     --read0
+    --remote
+    --remote-status
     --scheme
     --scroll-off
     --scrollbar
     --separator
     --smart-case
+    --source
     --style
     --sync
     --tabstop
@@ -214,6 +219,13 @@ _fzf_opts_completion() {
     --walker
     --walker-root
     --walker-skip
+    --walker-max-depth
+    --walker-ext
+    --walker-min-size
+    --walker-max-size
+    --walker-newer
+    --walker-format
+    --walker-watch
     --with-nth
     --with-shell
     --wrap
@@ -234,11 +246,11 @@ _fzf_opts_completion() {
 
   case "${prev}" in
     --scheme)
-      COMPREPLY=($(compgen -W "default path history" -- "$cur"))
+      COMPREPLY=($(compgen -W "default path history custom:" -- "$cur"))
       return 0
       ;;
     --tiebreak)
-      COMPREPLY=($(compgen -W "length chunk pathname begin end index" -- "$cur"))
+      COMPREPLY=($(compgen -W "length chunk pathname begin end frecency source index field:" -- "$cur"))
       return 0
       ;;
     --color)
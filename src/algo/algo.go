@@ -81,6 +81,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -109,6 +110,8 @@ type Result struct {
 	Score int
 }
 
+// Default scoring weights. Except for scoreMatch, they can be overridden by a
+// custom scheme.
 const (
 	scoreMatch        = 16
 	scoreGapStart     = -3
@@ -145,7 +148,23 @@ const (
 	bonusFirstCharMultiplier = 2
 )
 
+// FuzzyMatchV2 falls back to FuzzyMatchV1 for longer patterns
+const maxPatternLengthV2 = 1000
+
 var (
+	// Scoring weights of the current scheme
+	weights = Weights{
+		Boundary:            bonusBoundary,
+		BoundaryWhite:       bonusBoundary + 2,
+		BoundaryDelimiter:   bonusBoundary + 1,
+		NonWord:             bonusNonWord,
+		Camel:               bonusCamel123,
+		Consecutive:         bonusConsecutive,
+		GapStart:            scoreGapStart,
+		GapExtension:        scoreGapExtension,
+		FirstCharMultiplier: bonusFirstCharMultiplier,
+	}
+
 	// Extra bonus for word boundary after whitespace character or beginning of the string
 	bonusBoundaryWhite int16 = bonusBoundary + 2
 
@@ -174,25 +193,31 @@ const (
 )
 
 func Init(scheme string) bool {
-	switch scheme {
-	case "default":
-		bonusBoundaryWhite = bonusBoundary + 2
-		bonusBoundaryDelimiter = bonusBoundary + 1
-	case "path":
-		bonusBoundaryWhite = bonusBoundary
-		bonusBoundaryDelimiter = bonusBoundary + 1
+	w, _ := ParseWeights("")
+	switch {
+	case scheme == "default":
+	case strings.HasPrefix(scheme, CustomSchemePrefix):
+		custom, err := ParseWeights(scheme[len(CustomSchemePrefix):])
+		if err != nil {
+			return false
+		}
+		w = custom
+	case scheme == "path":
+		w.BoundaryWhite = w.Boundary
+		w.BoundaryDelimiter = w.Boundary + 1
 		if os.PathSeparator == '/' {
 			delimiterChars = "/"
 		} else {
 			delimiterChars = string([]rune{os.PathSeparator, '/'})
 		}
 		initialCharClass = charDelimiter
-	case "history":
-		bonusBoundaryWhite = bonusBoundary
-		bonusBoundaryDelimiter = bonusBoundary
+	case scheme == "history":
+		w.BoundaryWhite = w.Boundary
+		w.BoundaryDelimiter = w.Boundary
 	default:
 		return false
 	}
+	setWeights(w)
 	for i := 0; i <= unicode.MaxASCII; i++ {
 		char := rune(i)
 		c := charNonWord
@@ -276,19 +301,19 @@ func bonusFor(prevClass charClass, class charClass) int16 {
 			return bonusBoundaryDelimiter
 		case charNonWord:
 			// Word boundary
-			return bonusBoundary
+			return weights.Boundary
 		}
 	}
 
 	if prevClass == charLower && class == charUpper ||
 		prevClass != charNumber && class == charNumber {
 		// camelCase letter123
-		return bonusCamel123
+		return weights.Camel
 	}
 
 	switch class {
 	case charNonWord, charDelimiter:
-		return bonusNonWord
+		return weights.NonWord
 	case charWhite:
 		return bonusBoundaryWhite
 	}
@@ -525,10 +550,10 @@ func fuzzyMatchV2Single(caseSensitive bool, forward bool, input *util.Chars, b b
 			prevClass = asciiCharClasses[byteArray[idx-1]]
 		}
 		bonus := bonusMatrix[prevClass][class]
-		score := scoreMatch + bonus*bonusFirstCharMultiplier
+		score := scoreMatch + bonus*weights.FirstCharMultiplier
 		if forward && score > maxScore || !forward && score >= maxScore {
 			maxScore, maxScorePos = score, idx
-			if forward && bonus >= bonusBoundary {
+			if forward && bonus >= weights.Boundary {
 				break
 			}
 		}
@@ -612,14 +637,14 @@ func fuzzyMatchV2Two(caseSensitive bool, forward bool, input *util.Chars, pchar0
 		// Row 0 (pchar0)
 		var h0Cur, c0Cur int16
 		if lb == pchar0 {
-			h0Cur = scoreMatch + bonus*bonusFirstCharMultiplier
+			h0Cur = scoreMatch + bonus*weights.FirstCharMultiplier
 			c0Cur = 1
 			inGap0 = false
 		} else {
 			if inGap0 {
-				h0Cur = max(h0Prev+scoreGapExtension, 0)
+				h0Cur = max(h0Prev+weights.GapExtension, 0)
 			} else {
-				h0Cur = max(h0Prev+scoreGapStart, 0)
+				h0Cur = max(h0Prev+weights.GapStart, 0)
 			}
 			c0Cur = 0
 			inGap0 = true
@@ -636,9 +661,9 @@ func fuzzyMatchV2Two(caseSensitive bool, forward bool, input *util.Chars, pchar0
 				hleft = 0
 			}
 			if inGap1 {
-				s2 = hleft + scoreGapExtension
+				s2 = hleft + weights.GapExtension
 			} else {
-				s2 = hleft + scoreGapStart
+				s2 = hleft + weights.GapStart
 			}
 			if lb == pchar1 {
 				s1 = h0Prev + scoreMatch
@@ -646,10 +671,10 @@ func fuzzyMatchV2Two(caseSensitive bool, forward bool, input *util.Chars, pchar0
 				consecutive = c0Prev + 1
 				if consecutive > 1 {
 					fb := bPrev
-					if bb >= bonusBoundary && bb > fb {
+					if bb >= weights.Boundary && bb > fb {
 						consecutive = 1
 					} else {
-						bb = max(bb, bonusConsecutive, fb)
+						bb = max(bb, weights.Consecutive, fb)
 					}
 				}
 				if s1+bb < s2 {
@@ -737,7 +762,7 @@ func FuzzyMatchV2(caseSensitive bool, normalize bool, forward bool, input *util.
 	// we fall back to the greedy algorithm.
 	// Also, we should not allow a very long pattern to avoid 16-bit integer
 	// overflow in the score matrix. 1000 is a safe limit.
-	if slab != nil && int64(N)*int64(M) > int64(cap(slab.I16)) || M > 1000 {
+	if slab != nil && int64(N)*int64(M) > int64(cap(slab.I16)) || M > maxPatternLengthV2 {
 		return FuzzyMatchV1(caseSensitive, normalize, forward, input, pattern, withPos, slab)
 	}
 
@@ -812,21 +837,21 @@ func FuzzyMatchV2(caseSensitive bool, normalize bool, forward bool, input *util.
 		}
 
 		if char == pchar0 {
-			score := scoreMatch + bonus*bonusFirstCharMultiplier
+			score := scoreMatch + bonus*weights.FirstCharMultiplier
 			H0[off] = score
 			C0[off] = 1
 			if M == 1 && (forward && score > maxScore || !forward && score >= maxScore) {
 				maxScore, maxScorePos = score, off
-				if forward && bonus >= bonusBoundary {
+				if forward && bonus >= weights.Boundary {
 					break
 				}
 			}
 			inGap = false
 		} else {
 			if inGap {
-				H0[off] = max(prevH0+scoreGapExtension, 0)
+				H0[off] = max(prevH0+weights.GapExtension, 0)
 			} else {
-				H0[off] = max(prevH0+scoreGapStart, 0)
+				H0[off] = max(prevH0+weights.GapStart, 0)
 			}
 			C0[off] = 0
 			inGap = true
@@ -877,9 +902,9 @@ func FuzzyMatchV2(caseSensitive bool, normalize bool, forward bool, input *util.
 			var s1, s2, consecutive int16
 
 			if inGap {
-				s2 = Hleft[off] + scoreGapExtension
+				s2 = Hleft[off] + weights.GapExtension
 			} else {
-				s2 = Hleft[off] + scoreGapStart
+				s2 = Hleft[off] + weights.GapStart
 			}
 
 			if pchar == char {
@@ -889,10 +914,10 @@ func FuzzyMatchV2(caseSensitive bool, normalize bool, forward bool, input *util.
 				if consecutive > 1 {
 					fb := B[col-int(consecutive)+1]
 					// Break consecutive chunk
-					if b >= bonusBoundary && b > fb {
+					if b >= weights.Boundary && b > fb {
 						consecutive = 1
 					} else {
-						b = max(b, bonusConsecutive, fb)
+						b = max(b, weights.Consecutive, fb)
 					}
 				}
 				if s1+b < s2 {
@@ -958,6 +983,85 @@ func FuzzyMatchV2(caseSensitive bool, normalize bool, forward bool, input *util.
 	return Result{minIdx + j, minIdx + maxScorePos + 1, int(maxScore)}, pos
 }
 
+// CharScore is the breakdown of the score of a matched character
+type CharScore struct {
+	Index   int    `json:"index"`
+	Char    string `json:"char"`
+	Match   int    `json:"match"`
+	Bonus   int    `json:"bonus"`
+	Reason  string `json:"reason,omitempty"`
+	First   bool   `json:"first,omitempty"` // Bonus multiplied for the first character
+	Gap     int    `json:"gap,omitempty"`   // Number of unmatched characters before
+	Penalty int    `json:"penalty,omitempty"`
+}
+
+// bonusReason returns the name of the bonus given by bonusFor
+func bonusReason(prevClass charClass, class charClass) string {
+	if class >= charNonWord {
+		switch prevClass {
+		case charWhite:
+			return "boundary-white"
+		case charDelimiter:
+			return "boundary-delimiter"
+		case charNonWord:
+			return "boundary"
+		}
+	}
+	if prevClass == charLower && class == charUpper ||
+		prevClass != charNumber && class == charNumber {
+		return "camel"
+	}
+	switch class {
+	case charNonWord, charDelimiter:
+		return "non-word"
+	case charWhite:
+		return "white"
+	}
+	return ""
+}
+
+// ExplainScore breaks down the score of the matched positions in the same way
+// as calculateScore and FuzzyMatchV2. The positions should be sorted. Returns
+// the score of each character and the total score.
+func ExplainScore(text *util.Chars, positions []int) ([]CharScore, int) {
+	chars := make([]CharScore, len(positions))
+	total, consecutive, firstBonus := 0, 0, int16(0)
+	for i, idx := range positions {
+		prevClass := initialCharClass
+		if idx > 0 {
+			prevClass = charClassOf(text.Get(idx - 1))
+		}
+		class := charClassOf(text.Get(idx))
+		cs := CharScore{Index: idx, Char: string(text.Get(idx)), Match: scoreMatch, Reason: bonusReason(prevClass, class)}
+		if i > 0 && idx > positions[i-1]+1 {
+			cs.Gap = idx - positions[i-1] - 1
+			cs.Penalty = int(weights.GapStart) + (cs.Gap-1)*int(weights.GapExtension)
+			consecutive, firstBonus = 0, 0
+		}
+		bonus := bonusMatrix[prevClass][class]
+		if consecutive == 0 {
+			firstBonus = bonus
+		} else {
+			if bonus >= weights.Boundary && bonus > firstBonus {
+				firstBonus = bonus
+			}
+			if consecutiveBonus := max(firstBonus, weights.Consecutive); consecutiveBonus > bonus {
+				bonus = consecutiveBonus
+				cs.Reason = "consecutive"
+			}
+		}
+		if i == 0 {
+			bonus *= weights.FirstCharMultiplier
+			cs.First = true
+		}
+		cs.Bonus = int(bonus)
+		consecutive++
+		total += cs.Match + cs.Bonus + cs.Penalty
+		chars[i] = cs
+	}
+	return chars, total
+}
+
 // Implement the same sorting criteria as V2
 func calculateScore(caseSensitive bool, normalize bool, text *util.Chars, pattern []rune, sidx int, eidx int, withPos bool) (int, *[]int) {
 	pidx, score, inGap, consecutive, firstBonus := 0, 0, false, 0, int16(0)
@@ -990,13 +1094,13 @@ func calculateScore(caseSensitive bool, normalize bool, text *util.Chars, patter
 				firstBonus = bonus
 			} else {
 				// Break consecutive chunk
-				if bonus >= bonusBoundary && bonus > firstBonus {
+				if bonus >= weights.Boundary && bonus > firstBonus {
 					firstBonus = bonus
 				}
-				bonus = max(bonus, firstBonus, bonusConsecutive)
+				bonus = max(bonus, firstBonus, weights.Consecutive)
 			}
 			if pidx == 0 {
-				score += int(bonus * bonusFirstCharMultiplier)
+				score += int(bonus * weights.FirstCharMultiplier)
 			} else {
 				score += int(bonus)
 			}
@@ -1005,9 +1109,9 @@ func calculateScore(caseSensitive bool, normalize bool, text *util.Chars, patter
 			pidx++
 		} else {
 			if inGap {
-				score += scoreGapExtension
+				score += int(weights.GapExtension)
 			} else {
-				score += scoreGapStart
+				score += int(weights.GapStart)
 			}
 			inGap = true
 			consecutive = 0
@@ -1167,7 +1271,7 @@ func exactMatchNaive(caseSensitive bool, normalize bool, forward bool, boundaryC
 						bbonus = bonusBoundaryWhite
 					}
 				}
-				ok = bbonus >= bonusBoundary
+				ok = bbonus >= weights.Boundary
 				if ok && pidx_ == 0 {
 					ok = index_ == 0 || charClassOf(text.Get(index_-1)) <= charDelimiter
 				}
@@ -1182,7 +1286,7 @@ func exactMatchNaive(caseSensitive bool, normalize bool, forward bool, boundaryC
 				if bonus > bestBonus {
 					bestPos, bestBonus = index, bonus
 				}
-				if bonus >= bonusBoundary {
+				if bonus >= weights.Boundary {
 					break
 				}
 				index -= pidx - 1
@@ -1206,7 +1310,7 @@ func exactMatchNaive(caseSensitive bool, normalize bool, forward bool, boundaryC
 		if boundaryCheck {
 			// Underscore boundaries should be ranked lower than the other types of boundaries
 			score = int(bonus)
-			deduct := int(bonus-bonusBoundary) + 1
+			deduct := int(bonus-weights.Boundary) + 1
 			if sidx > 0 && text.Get(sidx-1) == '_' {
 				score -= deduct + 1
 				deduct = 1
@@ -1335,7 +1439,225 @@ func EqualMatch(caseSensitive bool, normalize bool, forward bool, text *util.Cha
 	}
 	if match {
 		return Result{trimmedLen, trimmedLen + lenPattern, (scoreMatch+int(bonusBoundaryWhite))*lenPattern +
-			(bonusFirstCharMultiplier-1)*int(bonusBoundaryWhite)}, nil
+			int(weights.FirstCharMultiplier-1)*int(bonusBoundaryWhite)}, nil
 	}
 	return Result{-1, -1, 0}, nil
 }
+
+// Maximum number of edits allowed by ApproximateMatch
+const maxApproximateEdits = 2
+
+// Penalty for each edit in ApproximateMatch
+const scoreEdit = -scoreMatch
+
+func approximateEdits(lenPattern int) int {
+	return min(maxApproximateEdits, lenPattern/3)
+}
+
+// approximateScore returns the score of an approximate match. A fuzzy match
+// scores at least scoreMatch regardless of its gaps, since the score matrix
+// of FuzzyMatchV2 never goes below zero and the last matched character adds
+// scoreMatch to it. The score is scaled below that floor so that approximate
+// matches are always ranked below fuzzy matches of the same pattern.
+func approximateScore(lenPattern int, matched int, edits int) int {
+	score := max(0, matched*scoreMatch+edits*scoreEdit)
+	return score * (scoreMatch - 1) / (lenPattern * scoreMatch)
+}
+
+// ApproximateMatch performs fuzzy-match, and if it fails, finds the substring
+// of the text that can be turned into the pattern with the smallest number of
+// edits (insertion, deletion, substitution, and transposition of two adjacent
+// characters). The number of edits is limited to one per three characters of
+// the pattern, up to maxApproximateEdits. Approximate matches are always ranked
+// below fuzzy matches, and each edit is penalized.
+func ApproximateMatch(caseSensitive bool, normalize bool, forward bool, text *util.Chars, pattern []rune, withPos bool, slab *util.Slab) (Result, *[]int) {
+	if res, pos := FuzzyMatchV2(caseSensitive, normalize, forward, text, pattern, withPos, slab); res.Start >= 0 {
+		return res, pos
+	}
+	M := len(pattern)
+	N := text.Length()
+	maxEdits := approximateEdits(M)
+	if maxEdits == 0 || N < M-maxEdits {
+		return Result{-1, -1, 0}, nil
+	}
+
+	// Characters of the text in the form of the pattern
+	_, T := alloc32(0, slab, N)
+	for idx := range N {
+		char := text.Get(idx)
+		if !caseSensitive {
+			char = unicode.ToLower(char)
+		}
+		if normalize {
+			char = normalizeRune(char)
+		}
+		T[idx] = char
+	}
+
+	// D[i*width+j]: edit distance between pattern[:i] and the best substring
+	// of the text ending at j. A match can start anywhere, so D[0][j] = 0.
+	width := N + 1
+	_, D := alloc16(0, slab, (M+1)*width)
+	for j := 0; j <= N; j++ {
+		D[j] = 0
+	}
+	for i := 1; i <= M; i++ {
+		row := i * width
+		D[row] = int16(i)
+		for j := 1; j <= N; j++ {
+			cost := int16(1)
+			if pattern[i-1] == T[j-1] {
+				cost = 0
+			}
+			d := min(D[row-width+j]+1, D[row+j-1]+1, D[row-width+j-1]+cost)
+			if i > 1 && j > 1 && pattern[i-1] == T[j-2] && pattern[i-2] == T[j-1] {
+				d = min(d, D[row-2*width+j-2]+1)
+			}
+			D[row+j] = d
+		}
+	}
+
+	// Find the smallest number of edits
+	last := M * width
+	edits := int16(maxEdits + 1)
+	for j := 0; j <= N; j++ {
+		edits = min(edits, D[last+j])
+	}
+	if edits > int16(maxEdits) {
+		return Result{-1, -1, 0}, nil
+	}
+
+	// Among the substrings with the smallest number of edits, choose the one
+	// with the most matched characters
+	start, end, matched := -1, -1, -1
+	for j := 0; j <= N; j++ {
+		if D[last+j] != edits {
+			continue
+		}
+		if s, m, _ := traceApproximateMatch(D, width, T, pattern, j, false); m > matched || !forward && m == matched {
+			start, end, matched = s, j, m
+		}
+	}
+	_, _, pos := traceApproximateMatch(D, width, T, pattern, end, withPos)
+	return Result{start, end, approximateScore(M, matched, int(edits))}, pos
+}
+
+// traceApproximateMatch traces back the edit distance matrix of
+// ApproximateMatch from the end of a match, and returns the start of the
+// match, the number of matched characters, and their positions
+func traceApproximateMatch(D []int16, width int, T []int32, pattern []rune, end int, withPos bool) (int, int, *[]int) {
+	pos := posArray(withPos, len(pattern))
+	matched := 0
+	i, j := len(pattern), end
+	for i > 0 {
+		row := i * width
+		d := D[row+j]
+		if i > 1 && j > 1 && pattern[i-1] == T[j-2] && pattern[i-2] == T[j-1] && pattern[i-1] != T[j-1] && d == D[row-2*width+j-2]+1 {
+			if withPos {
+				*pos = append(*pos, j-1, j-2)
+			}
+			matched += 2
+			i, j = i-2, j-2
+		} else if j > 0 && pattern[i-1] == T[j-1] && d == D[row-width+j-1] {
+			if withPos {
+				*pos = append(*pos, j-1)
+			}
+			matched++
+			i, j = i-1, j-1
+		} else if j > 0 && d == D[row-width+j-1]+1 {
+			i, j = i-1, j-1
+		} else if d == D[row-width+j]+1 {
+			i--
+		} else {
+			j--
+		}
+	}
+	return j, matched, pos
+}
+
+// InitialsMatch matches the pattern against the initials of the words in the
+// text, i.e. the characters at word boundaries and at the humps of camelCase
+// words (e.g. "hrc" on "HttpRequestContext", "gcp" on "get_current_path").
+// The leftmost occurrence is chosen. The score of an initials match is
+// higher than any score FuzzyMatchV2 can give to the pattern, and the matches
+// with fewer skipped initials are preferred. If the pattern is not found in
+// the initials, it falls back to FuzzyMatchV2 so that the scattered matches
+// are ranked below.
+func InitialsMatch(caseSensitive bool, normalize bool, forward bool, text *util.Chars, pattern []rune, withPos bool, slab *util.Slab) (Result, *[]int) {
+	M := len(pattern)
+	if M == 0 {
+		return Result{0, 0, 0}, nil
+	}
+	N := text.Length()
+	pos := posArray(withPos, M)
+	pidx, sidx, eidx := 0, -1, -1
+	bonusSum, skipped := 0, 0
+	for idx := 0; idx < N && pidx < M; idx++ {
+		char := text.Get(idx)
+		bonus := bonusAt(text, idx)
+		if charClassOf(char) < charLower || bonus < weights.Camel {
+			continue
+		}
+		if !caseSensitive {
+			char = unicode.ToLower(char)
+		}
+		if normalize {
+			char = normalizeRune(char)
+		}
+		if char != pattern[pidx] {
+			if sidx >= 0 {
+				skipped++
+			}
+			continue
+		}
+		if sidx < 0 {
+			sidx = idx
+			bonus *= weights.FirstCharMultiplier
+		}
+		eidx = idx + 1
+		bonusSum += int(bonus)
+		if withPos {
+			*pos = append(*pos, idx)
+		}
+		pidx++
+	}
+	if pidx < M {
+		return FuzzyMatchV2(caseSensitive, normalize, forward, text, pattern, withPos, slab)
+	}
+	base := M * (scoreMatch + int(weights.FirstCharMultiplier*bonusBoundaryWhite))
+	score := base + max(0, bonusSum-skipped*int(weights.Boundary))
+	return Result{sidx, eidx, score}, pos
+}
+
+// RegexMatch returns an Algo that finds the leftmost match of the regular
+// expression. The pattern argument of the returned function is ignored, and
+// case sensitivity and normalization should be handled by the expression.
+// The match is scored as if it were an exact match of the matched string.
+func RegexMatch(re *regexp.Regexp) Algo {
+	return func(caseSensitive bool, normalize bool, forward bool, text *util.Chars, pattern []rune, withPos bool, slab *util.Slab) (Result, *[]int) {
+		var sidx, eidx int
+		if text.IsBytes() {
+			// ASCII only, byte offsets are rune offsets
+			loc := re.FindIndex(text.Bytes())
+			if loc == nil {
+				return Result{-1, -1, 0}, nil
+			}
+			sidx, eidx = loc[0], loc[1]
+		} else {
+			str := text.ToString()
+			loc := re.FindStringIndex(str)
+			if loc == nil {
+				return Result{-1, -1, 0}, nil
+			}
+			sidx = utf8.RuneCountInString(str[:loc[0]])
+			eidx = sidx + utf8.RuneCountInString(str[loc[0]:loc[1]])
+		}
+
+		matched := make([]rune, eidx-sidx)
+		for idx := range matched {
+			matched[idx] = text.Get(sidx + idx)
+		}
+		score, pos := calculateScore(true, false, text, matched, sidx, eidx, withPos)
+		return Result{sidx, eidx, score}, pos
+	}
+}
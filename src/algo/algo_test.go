@@ -2,6 +2,7 @@ package algo
 
 import (
 	"math"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -156,6 +157,137 @@ func TestPrefixMatch(t *testing.T) {
 	}
 }
 
+func TestApproximateMatch(t *testing.T) {
+	for _, dir := range []bool{true, false} {
+		// Fuzzy match is preferred
+		assertMatch(t, ApproximateMatch, false, dir, "fooBarbaz1", "oBZ", 2, 9,
+			scoreMatch*3+bonusCamel123+scoreGapStart+scoreGapExtension*3)
+
+		// Transposition
+		assertMatch(t, ApproximateMatch, false, dir, "I receive it", "recieve", 2, 9, approximateScore(7, 7, 1))
+		assertMatch(t, ApproximateMatch, false, dir, "the cat", "teh", 0, 3, approximateScore(3, 3, 1))
+
+		// Substitution, insertion, and deletion
+		assertMatch(t, ApproximateMatch, false, dir, "separate", "seperate", 0, 8, approximateScore(8, 7, 1))
+		assertMatch(t, ApproximateMatch, false, dir, "a beleive b", "believe", 2, 9, approximateScore(7, 7, 1))
+		assertMatch(t, ApproximateMatch, false, dir, "tomorow", "tomorrow", 0, 7, approximateScore(8, 7, 1))
+		assertMatch(t, ApproximateMatch, false, dir, "tommorow", "tomorrow", 0, 8, approximateScore(8, 6, 2))
+
+		// Too many edits
+		assertMatch(t, ApproximateMatch, false, dir, "tomorrow", "tamarraw", -1, -1, 0)
+		assertMatch(t, ApproximateMatch, false, dir, "tax cut", "teh", -1, -1, 0)
+		assertMatch(t, ApproximateMatch, false, dir, "ab", "ba", -1, -1, 0)
+	}
+}
+
+func TestApproximateMatchRankedBelowFuzzyMatch(t *testing.T) {
+	score := func(text string, pattern string) int {
+		chars := util.ToChars([]byte(text))
+		res, _ := ApproximateMatch(false, false, true, &chars, []rune(pattern), false, nil)
+		if res.Start < 0 {
+			t.Fatalf("%s should match %s", pattern, text)
+		}
+		return res.Score
+	}
+	gap := strings.Repeat("_", 100)
+	fuzzy := score(strings.Join(strings.Split("recieve", ""), gap), "recieve")
+	for _, text := range []string{"receive", "I receive it", "recive"} {
+		if approx := score(text, "recieve"); approx >= fuzzy {
+			t.Errorf("approximate match of %q (%d) should be ranked below fuzzy match (%d)", text, approx, fuzzy)
+		}
+	}
+}
+
+func TestInitialsMatch(t *testing.T) {
+	base := scoreMatch + bonusFirstCharMultiplier*int(bonusBoundaryWhite)
+	for _, dir := range []bool{true, false} {
+		assertMatch(t, InitialsMatch, false, dir, "HttpRequestContext", "hrc", 0, 12,
+			base*3+int(bonusBoundaryWhite)*bonusFirstCharMultiplier+bonusCamel123*2)
+		assertMatch(t, InitialsMatch, false, dir, "src/get_current_path.go", "gcp", 4, 17,
+			base*3+int(bonusBoundaryDelimiter)*bonusFirstCharMultiplier+bonusBoundary*2)
+
+		// Skipped initials are penalized
+		assertMatch(t, InitialsMatch, false, dir, "get_the_current_path", "gcp", 0, 17,
+			base*3+int(bonusBoundaryWhite)*bonusFirstCharMultiplier+bonusBoundary)
+
+		// Falls back to FuzzyMatchV2
+		assertMatch(t, InitialsMatch, false, dir, "fooBarbaz1", "oBZ", 2, 9,
+			scoreMatch*3+bonusCamel123+scoreGapStart+scoreGapExtension*3)
+		assertMatch(t, InitialsMatch, false, dir, "HTTPRequest", "hr", 0, 5,
+			scoreMatch*2+int(bonusBoundaryWhite)*bonusFirstCharMultiplier+scoreGapStart+scoreGapExtension*2)
+		assertMatch(t, InitialsMatch, false, dir, "foobar", "xyz", -1, -1, 0)
+	}
+
+	// Full-initial matches are ranked above the consecutive matches
+	for _, input := range []string{"hrc", "hrc_foo", "h.r.c"} {
+		chars := util.ToChars([]byte(input))
+		scattered, _ := FuzzyMatchV2(false, false, true, &chars, []rune("hrc"), false, nil)
+		chars = util.ToChars([]byte("HttpRequestContextFactory"))
+		initials, _ := InitialsMatch(false, false, true, &chars, []rune("hrc"), false, nil)
+		if initials.Score <= scattered.Score {
+			t.Errorf("Expected %d > %d (%s)", initials.Score, scattered.Score, input)
+		}
+	}
+}
+
+func TestExplainScore(t *testing.T) {
+	for _, fn := range []Algo{FuzzyMatchV1, FuzzyMatchV2, ExactMatchNaive} {
+		for _, c := range [][2]string{
+			{"fooBarbaz1", "obz"},
+			{"foo-bar", "fb"},
+			{"/AutomatorDocument.icns", "rdoc"},
+			{"/man1/zshcompctl.1", "zshc"},
+			{"fooBarBaz", "fbb"},
+			{"xxx fozzo bar", "ozb"},
+		} {
+			chars := util.ToChars([]byte(c[0]))
+			res, pos := fn(false, false, true, &chars, []rune(c[1]), true, nil)
+			if res.Start < 0 {
+				continue
+			}
+			if pos == nil {
+				pos = &[]int{}
+				for idx := res.Start; idx < res.End; idx++ {
+					*pos = append(*pos, idx)
+				}
+			}
+			sort.Ints(*pos)
+			explained, total := ExplainScore(&chars, *pos)
+			if total != res.Score || len(explained) != len(*pos) {
+				t.Errorf("Expected %d, got %d (%s / %s): %v", res.Score, total, c[0], c[1], explained)
+			}
+		}
+	}
+
+	chars := util.ToChars([]byte("foo-barBaz"))
+	explained, _ := ExplainScore(&chars, []int{0, 4, 5, 7})
+	expected := []CharScore{
+		{Index: 0, Char: "f", Match: scoreMatch, Bonus: int(bonusBoundaryWhite) * int(bonusFirstCharMultiplier), Reason: "boundary-white", First: true},
+		{Index: 4, Char: "b", Match: scoreMatch, Bonus: int(bonusBoundary), Reason: "boundary", Gap: 3, Penalty: int(scoreGapStart) + int(scoreGapExtension)*2},
+		{Index: 5, Char: "a", Match: scoreMatch, Bonus: int(bonusBoundary), Reason: "consecutive"},
+		{Index: 7, Char: "B", Match: scoreMatch, Bonus: int(bonusCamel123), Reason: "camel", Gap: 1, Penalty: int(scoreGapStart)},
+	}
+	for idx, cs := range explained {
+		if cs != expected[idx] {
+			t.Errorf("Expected %v, got %v", expected[idx], cs)
+		}
+	}
+}
+
+func TestRegexMatch(t *testing.T) {
+	fn := RegexMatch(regexp.MustCompile(`\d+(\.\d+)*-rc`))
+	for _, input := range []string{"fzf 0.75.0-rc1", "fzf 0.75.0-rc1 ✔", "✔ fzf 0.75.0-rc1"} {
+		chars := util.ToChars([]byte(input))
+		expected, _ := ExactMatchNaive(true, false, true, &chars, []rune("0.75.0-rc"), false, nil)
+		sidx := expected.Start
+		assertMatch(t, fn, true, true, input, "", sidx, sidx+9, expected.Score)
+	}
+	assertMatch(t, fn, true, true, "fzf 0.75.0", "", -1, -1, 0)
+
+	// Empty match
+	assertMatch(t, RegexMatch(regexp.MustCompile(`^`)), true, true, "foo", "", 0, 0, 0)
+}
+
 func TestSuffixMatch(t *testing.T) {
 	for _, dir := range []bool{true, false} {
 		assertMatch(t, SuffixMatch, true, dir, "fooBarbaz", "Baz", -1, -1, 0)
@@ -0,0 +1,136 @@
+package algo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CustomSchemePrefix is the prefix of a custom scheme that overrides the
+// scoring weights of the default scheme (e.g. custom:boundary=10,camel=8)
+const CustomSchemePrefix = "custom:"
+
+const (
+	maxFirstCharMultiplier = 8
+
+	// FuzzyMatchV2 computes the scores in int16, so the score of the longest
+	// pattern whose characters all get the maximum bonus should fit in int16
+	maxBonus = (math.MaxInt16 - maxPatternLengthV2*scoreMatch) / (maxPatternLengthV2 + maxFirstCharMultiplier - 1)
+
+	// The score matrix never goes below zero, so the penalties don't add up
+	maxGapPenalty = 64
+)
+
+// Weights are the scoring weights of a scheme
+type Weights struct {
+	Boundary            int16
+	BoundaryWhite       int16
+	BoundaryDelimiter   int16
+	NonWord             int16
+	Camel               int16
+	Consecutive         int16
+	GapStart            int16
+	GapExtension        int16
+	FirstCharMultiplier int16
+}
+
+// weightNames are the names of the weights in a custom scheme in the order
+// they are printed
+var weightNames = []string{
+	"boundary",
+	"boundary-white",
+	"boundary-delimiter",
+	"non-word",
+	"camel",
+	"consecutive",
+	"gap-start",
+	"gap-extension",
+	"first-char-multiplier",
+}
+
+// ParseWeights parses the comma-separated list of NAME=VALUE pairs of a
+// custom scheme. The weights not in the list are derived from the others in
+// the same way as the default scheme, e.g. the camelCase bonus defaults to
+// the boundary bonus plus the gap extension penalty, up to maxBonus.
+func ParseWeights(str string) (Weights, error) {
+	values := make(map[string]int16)
+	for _, pair := range strings.Split(str, ",") {
+		if len(pair) == 0 {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			return Weights{}, errors.New("invalid weight: " + pair + " (expected: NAME=VALUE)")
+		}
+		if !slices.Contains(weightNames, name) {
+			return Weights{}, fmt.Errorf("unknown weight: %s (expected: %s)", name, strings.Join(weightNames, "|"))
+		}
+		num, err := strconv.Atoi(value)
+		if err != nil {
+			return Weights{}, fmt.Errorf("invalid value for %s: %s (expected: integer)", name, value)
+		}
+		minValue, maxValue := 0, maxBonus
+		if name == "first-char-multiplier" {
+			minValue, maxValue = 1, maxFirstCharMultiplier
+		} else if strings.HasPrefix(name, "gap-") {
+			minValue, maxValue = -maxGapPenalty, 0
+		}
+		if num < minValue || num > maxValue {
+			return Weights{}, fmt.Errorf("%s must be between %d and %d: %d", name, minValue, maxValue, num)
+		}
+		values[name] = int16(num)
+	}
+
+	get := func(name string, def int16) int16 {
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return def
+	}
+	w := Weights{
+		GapStart:            get("gap-start", scoreGapStart),
+		GapExtension:        get("gap-extension", scoreGapExtension),
+		Boundary:            get("boundary", bonusBoundary),
+		FirstCharMultiplier: get("first-char-multiplier", bonusFirstCharMultiplier),
+	}
+	w.NonWord = get("non-word", w.Boundary)
+	w.Camel = get("camel", max(0, w.Boundary+w.GapExtension))
+	w.Consecutive = get("consecutive", min(maxBonus, -(w.GapStart+w.GapExtension)))
+	w.BoundaryWhite = get("boundary-white", min(maxBonus, w.Boundary+2))
+	w.BoundaryDelimiter = get("boundary-delimiter", min(maxBonus, w.Boundary+1))
+	return w, nil
+}
+
+// String returns the weights in the format of a custom scheme
+func (w Weights) String() string {
+	values := []int16{
+		w.Boundary,
+		w.BoundaryWhite,
+		w.BoundaryDelimiter,
+		w.NonWord,
+		w.Camel,
+		w.Consecutive,
+		w.GapStart,
+		w.GapExtension,
+		w.FirstCharMultiplier,
+	}
+	pairs := make([]string, len(values))
+	for idx, value := range values {
+		pairs[idx] = fmt.Sprintf("%s=%d", weightNames[idx], value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// CurrentWeights returns the weights of the current scheme
+func CurrentWeights() Weights {
+	return weights
+}
+
+func setWeights(w Weights) {
+	weights = w
+	bonusBoundaryWhite = w.BoundaryWhite
+	bonusBoundaryDelimiter = w.BoundaryDelimiter
+}
@@ -0,0 +1,110 @@
+package algo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+func TestParseWeights(t *testing.T) {
+	// The default weights
+	weights, err := ParseWeights("")
+	if err != nil || weights != CurrentWeights() {
+		t.Errorf("Unexpected default weights: %v", weights)
+	}
+	if weights.String() != "boundary=8,boundary-white=10,boundary-delimiter=9,non-word=8,camel=7,consecutive=4,gap-start=-3,gap-extension=-1,first-char-multiplier=2" {
+		t.Errorf("Unexpected string representation: %s", weights)
+	}
+
+	// Unspecified weights are derived from the others
+	weights, err = ParseWeights("boundary=10,gap-start=-5,camel=3,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Weights{
+		Boundary:            10,
+		BoundaryWhite:       12,
+		BoundaryDelimiter:   11,
+		NonWord:             10,
+		Camel:               3,
+		Consecutive:         6,
+		GapStart:            -5,
+		GapExtension:        -1,
+		FirstCharMultiplier: 2,
+	}
+	if weights != expected {
+		t.Errorf("Expected %v, got %v", expected, weights)
+	}
+	if parsed, _ := ParseWeights(weights.String()); parsed != weights {
+		t.Errorf("Expected %v, got %v", weights, parsed)
+	}
+
+	// Derived bonuses are capped
+	weights, _ = ParseWeights("boundary=16,gap-start=-64")
+	if weights.BoundaryWhite != maxBonus || weights.BoundaryDelimiter != maxBonus || weights.Consecutive != maxBonus {
+		t.Errorf("Derived bonuses should not exceed %d: %v", maxBonus, weights)
+	}
+
+	for str, message := range map[string]string{
+		"boundary":                "invalid weight: boundary",
+		"foo=1":                   "unknown weight: foo",
+		"camel=x":                 "invalid value for camel: x",
+		"camel=17":                "camel must be between 0 and 16",
+		"boundary=-1":             "boundary must be between 0 and 16",
+		"gap-start=-65":           "gap-start must be between -64 and 0",
+		"gap-start=1":             "gap-start must be between -64 and 0",
+		"first-char-multiplier=0": "first-char-multiplier must be between 1 and 8",
+	} {
+		if _, err := ParseWeights(str); err == nil || !strings.HasPrefix(err.Error(), message) {
+			t.Errorf("Unexpected error for %q: %v", str, err)
+		}
+	}
+}
+
+func TestCustomScheme(t *testing.T) {
+	defer Init("default")
+
+	score := func() int {
+		chars := util.ToChars([]byte("foo-bar"))
+		res, _ := FuzzyMatchV2(false, false, true, &chars, []rune("fb"), false, nil)
+		return res.Score
+	}
+	defaultScore := score()
+
+	if Init("custom:foo=1") {
+		t.Error("Expected invalid scheme")
+	}
+	if !Init("custom:boundary=16,boundary-white=16") {
+		t.Fatal("Expected valid scheme")
+	}
+	if customScore := score(); customScore != scoreMatch*2+16*2+16+scoreGapStart+scoreGapExtension*2 || customScore <= defaultScore {
+		t.Errorf("Unexpected score: %d (default: %d)", customScore, defaultScore)
+	}
+
+	// Built-in schemes reset the weights
+	Init("default")
+	if score() != defaultScore {
+		t.Errorf("Expected %d, got %d", defaultScore, score())
+	}
+}
+
+func TestCustomSchemeLimits(t *testing.T) {
+	defer Init("default")
+
+	if !Init(fmt.Sprintf("custom:boundary=%d,non-word=%d,camel=%d,consecutive=%d,first-char-multiplier=%d",
+		maxBonus, maxBonus, maxBonus, maxBonus, maxFirstCharMultiplier)) {
+		t.Fatal("Expected valid scheme")
+	}
+
+	// The longest pattern scored by FuzzyMatchV2 with the maximum bonuses
+	text := strings.Repeat("a", maxPatternLengthV2)
+	chars := util.ToChars([]byte(text))
+	res, _ := FuzzyMatchV2(false, false, true, &chars, []rune(text), false, nil)
+	expected := maxPatternLengthV2*(scoreMatch+maxBonus) + (maxFirstCharMultiplier-1)*maxBonus
+	if expected > math.MaxInt16 || res.Score != expected {
+		t.Errorf("Expected %d, got %d", expected, res.Score)
+	}
+}
@@ -1,6 +1,10 @@
 package fzf
 
-import "sync"
+import (
+	"math/bits"
+	"strings"
+	"sync"
+)
 
 // ChunkBitmap is a bitmap with one bit per item in a chunk.
 type ChunkBitmap [chunkBitWords]uint64
@@ -8,20 +12,42 @@ type ChunkBitmap [chunkBitWords]uint64
 // queryCache associates query strings to bitmaps of matching items
 type queryCache map[string]ChunkBitmap
 
+// TermBitmap is the result of a search term of an extended-search query.
+// Unlike the bitmap of a query, it can be partial; the term may not have been
+// evaluated against the items that didn't match the other terms.
+type TermBitmap struct {
+	matched ChunkBitmap
+	scope   ChunkBitmap // Items the term was evaluated against
+}
+
+// candidates returns the bitmap of the items that can match the term
+func (tb *TermBitmap) candidates() ChunkBitmap {
+	var bitmap ChunkBitmap
+	for idx := range bitmap {
+		bitmap[idx] = tb.matched[idx] | ^tb.scope[idx]
+	}
+	return bitmap
+}
+
+// termCache associates search terms to their results
+type termCache map[string]TermBitmap
+
 // ChunkCache associates Chunk and query string to bitmaps
 type ChunkCache struct {
 	mutex sync.Mutex
 	cache map[*Chunk]*queryCache
+	terms map[*Chunk]*termCache
 }
 
 // NewChunkCache returns a new ChunkCache
 func NewChunkCache() *ChunkCache {
-	return &ChunkCache{sync.Mutex{}, make(map[*Chunk]*queryCache)}
+	return &ChunkCache{sync.Mutex{}, make(map[*Chunk]*queryCache), make(map[*Chunk]*termCache)}
 }
 
 func (cc *ChunkCache) Clear() {
 	cc.mutex.Lock()
 	cc.cache = make(map[*Chunk]*queryCache)
+	cc.terms = make(map[*Chunk]*termCache)
 	cc.mutex.Unlock()
 }
 
@@ -29,6 +55,7 @@ func (cc *ChunkCache) retire(chunk ...*Chunk) {
 	cc.mutex.Lock()
 	for _, c := range chunk {
 		delete(cc.cache, c)
+		delete(cc.terms, c)
 	}
 	cc.mutex.Unlock()
 }
@@ -70,7 +97,7 @@ func (cc *ChunkCache) Lookup(chunk *Chunk, key string) *ChunkBitmap {
 
 // Search finds the bitmap for the longest prefix or suffix of the key
 func (cc *ChunkCache) Search(chunk *Chunk, key string) *ChunkBitmap {
-	if len(key) == 0 || !chunk.IsFull() {
+	if len(key) == 0 || !chunk.IsFull() || strings.Contains(key, regexCacheKeyPrefix) {
 		return nil
 	}
 
@@ -96,3 +123,95 @@ func (cc *ChunkCache) Search(chunk *Chunk, key string) *ChunkBitmap {
 	}
 	return nil
 }
+
+// AddTerms merges the results of the search terms into the cache. Empty keys
+// are for the terms that are not cacheable.
+func (cc *ChunkCache) AddTerms(chunk *Chunk, keys []string, results []TermBitmap) {
+	if !chunk.IsFull() {
+		return
+	}
+
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	tc, ok := cc.terms[chunk]
+	if !ok {
+		tc = &termCache{}
+		cc.terms[chunk] = tc
+	}
+	for idx, key := range keys {
+		if len(key) == 0 {
+			continue
+		}
+		merged := (*tc)[key]
+		matchCount := 0
+		for i := range merged.matched {
+			merged.matched[i] |= results[idx].matched[i]
+			merged.scope[i] |= results[idx].scope[i]
+			matchCount += bits.OnesCount64(merged.matched[i])
+		}
+		if matchCount <= queryCacheMax {
+			(*tc)[key] = merged
+		}
+	}
+}
+
+// SearchTerms returns the bitmap of the items that can match all the given
+// search terms, or nil if the cache has no information about any of them.
+// The result of a term is looked up by its exact key first, then by the
+// longest prefix or suffix of the key like Search.
+func (cc *ChunkCache) SearchTerms(chunk *Chunk, keys []string) *ChunkBitmap {
+	if !chunk.IsFull() {
+		return nil
+	}
+
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	qc := cc.cache[chunk]
+	tc := cc.terms[chunk]
+	if qc == nil && tc == nil {
+		return nil
+	}
+
+	// The bitmap of a single-term query is the complete result of the term
+	lookup := func(key string) *ChunkBitmap {
+		if qc != nil {
+			if bm, found := (*qc)[key]; found {
+				return &bm
+			}
+		}
+		if tc != nil {
+			if tb, found := (*tc)[key]; found {
+				bm := tb.candidates()
+				return &bm
+			}
+		}
+		return nil
+	}
+	var result *ChunkBitmap
+	for _, key := range keys {
+		if len(key) == 0 {
+			continue
+		}
+		bm := lookup(key)
+		if !strings.HasPrefix(key, regexCacheKeyPrefix) {
+			for idx := 1; bm == nil && idx < len(key); idx++ {
+				if bm = lookup(key[:len(key)-idx]); bm == nil {
+					bm = lookup(key[idx:])
+				}
+			}
+		}
+		if bm == nil {
+			continue
+		}
+		if result == nil {
+			result = bm
+			continue
+		}
+		for idx := range result {
+			result[idx] &= bm[idx]
+		}
+	}
+	return result
+}
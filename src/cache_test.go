@@ -36,4 +36,66 @@ func TestChunkCache(t *testing.T) {
 			t.Error("Expected nil cached", cached)
 		}
 	}
+	{ // The result of a regular expression is not a subset of its substring
+		cache.Add(chunk2p, regexCacheKeyPrefix+"ab", bm1, 1)
+		if cached := cache.Lookup(chunk2p, regexCacheKeyPrefix+"ab"); cached == nil {
+			t.Error("Expected bitmap cached", cached)
+		}
+		if cached := cache.Search(chunk2p, regexCacheKeyPrefix+"ab?"); cached != nil {
+			t.Error("Expected nil cached", cached)
+		}
+	}
+}
+
+func TestChunkCacheTerms(t *testing.T) {
+	cache := NewChunkCache()
+	chunk := &Chunk{count: chunkSize}
+
+	// foo is evaluated against the first 3 items and matches the first two,
+	// bar is evaluated against all the items in the first word
+	cache.AddTerms(chunk, []string{"foo", "", "bar"}, []TermBitmap{
+		{matched: ChunkBitmap{0b011}, scope: ChunkBitmap{0b111}},
+		{matched: ChunkBitmap{0b111}, scope: ChunkBitmap{0b111}},
+		{matched: ChunkBitmap{0b110}, scope: ChunkBitmap{^uint64(0)}}})
+	if cache.SearchTerms(chunk, []string{"baz"}) != nil {
+		t.Error("Expected nil for unknown term")
+	}
+	if cache.SearchTerms(&Chunk{}, []string{"foo"}) != nil {
+		t.Error("Cache disabled for non-full chunks")
+	}
+
+	// The items not evaluated against the term can still match
+	if cached := cache.SearchTerms(chunk, []string{"foo"}); cached == nil || cached[0] != ^uint64(0b100) || cached[1] != ^uint64(0) {
+		t.Error("Unexpected bitmap", cached)
+	}
+	// Intersection of the terms, and the results of foo for foox
+	if cached := cache.SearchTerms(chunk, []string{"bar", "", "foox"}); cached == nil || cached[0] != 0b010 || cached[1] != ^uint64(0) {
+		t.Error("Unexpected bitmap", cached)
+	}
+
+	// Results are merged
+	cache.AddTerms(chunk, []string{"foo"}, []TermBitmap{{matched: ChunkBitmap{0b1000}, scope: ChunkBitmap{0b1000}}})
+	if cached := cache.SearchTerms(chunk, []string{"foo"}); cached == nil || cached[0] != ^uint64(0b100) {
+		t.Error("Unexpected bitmap", cached)
+	}
+
+	// The bitmap of a single-term query is used as the result of the term
+	cache.Add(chunk, "qux", ChunkBitmap{0b1}, 1)
+	if cached := cache.SearchTerms(chunk, []string{"quxx", "foo"}); cached == nil || cached[0] != 0b1 || cached[1] != 0 {
+		t.Error("Unexpected bitmap", cached)
+	}
+
+	// A regular expression is only looked up by its exact key
+	cache.AddTerms(chunk, []string{regexCacheKeyPrefix + "ab"}, []TermBitmap{{matched: ChunkBitmap{0b1}, scope: ChunkBitmap{0b11}}})
+	if cached := cache.SearchTerms(chunk, []string{regexCacheKeyPrefix + "ab?"}); cached != nil {
+		t.Error("Expected nil cached", cached)
+	}
+	if cached := cache.SearchTerms(chunk, []string{regexCacheKeyPrefix + "ab"}); cached == nil || cached[0] != ^uint64(0b10) {
+		t.Error("Unexpected bitmap", cached)
+	}
+
+	cache.Clear()
+	if cache.SearchTerms(chunk, []string{"foo"}) != nil {
+		t.Error("Expected nil after clear")
+	}
 }
@@ -28,8 +28,9 @@ func NewChunkList(cache *ChunkCache, trans ItemBuilder) *ChunkList {
 		cache:  cache}
 }
 
-func (c *Chunk) push(trans ItemBuilder, data []byte) bool {
+func (c *Chunk) push(trans ItemBuilder, data []byte, source uint8) bool {
 	if trans(&c.items[c.count], data) {
+		c.items[c.count].text.SetSource(source)
 		c.count++
 		return true
 	}
@@ -81,15 +82,35 @@ func CountItems(cs []*Chunk) int {
 
 // Push adds the item to the list
 func (cl *ChunkList) Push(data []byte) bool {
+	return cl.PushSource(data, 0)
+}
+
+// PushSource adds the item from the input source of the 1-based index
+func (cl *ChunkList) PushSource(data []byte, source uint8) bool {
+	_, ret := cl.push(data, source)
+	return ret
+}
+
+// PushIndex adds the item and returns its index
+func (cl *ChunkList) PushIndex(data []byte) (int32, bool) {
+	return cl.push(data, 0)
+}
+
+func (cl *ChunkList) push(data []byte, source uint8) (int32, bool) {
 	cl.mutex.Lock()
 
 	if len(cl.chunks) == 0 || cl.lastChunk().IsFull() {
 		cl.chunks = append(cl.chunks, &Chunk{})
 	}
 
-	ret := cl.lastChunk().push(cl.trans, data)
+	chunk := cl.lastChunk()
+	ret := chunk.push(cl.trans, data, source)
+	index := int32(-1)
+	if ret {
+		index = chunk.items[chunk.count-1].Index()
+	}
 	cl.mutex.Unlock()
-	return ret
+	return index, ret
 }
 
 // Clear clears the data
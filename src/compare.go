@@ -0,0 +1,125 @@
+package fzf
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type compareOp int
+
+const (
+	compareEq compareOp = iota
+	compareLt
+	compareLe
+	compareGt
+	compareGe
+)
+
+// comparison is the condition of a comparison term (e.g. 3:>10M, 2:<=2024-01-01)
+type comparison struct {
+	op    compareOp
+	value float64
+	date  bool // The fields are compared as dates
+}
+
+var _numberRegex *regexp.Regexp
+
+// Layouts of the dates in the order of precedence. Dates without time zone
+// are in local time.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func init() {
+	// Number with an optional size unit (e.g. 100, -1.5, 10K, 2.5MiB, 1gb)
+	_numberRegex = regexp.MustCompile(`^([+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+))(?i:([kmgtp])(?:i?b)?|b)?$`)
+}
+
+// parseComparison parses the operator and the operand of a comparison term.
+// Returns false if the text is not a comparison, so that it can be matched
+// as text.
+func parseComparison(text string) (*comparison, bool) {
+	var op compareOp
+	switch {
+	case strings.HasPrefix(text, ">="):
+		op, text = compareGe, text[2:]
+	case strings.HasPrefix(text, "<="):
+		op, text = compareLe, text[2:]
+	case strings.HasPrefix(text, ">"):
+		op, text = compareGt, text[1:]
+	case strings.HasPrefix(text, "<"):
+		op, text = compareLt, text[1:]
+	case strings.HasPrefix(text, "="):
+		op, text = compareEq, text[1:]
+	default:
+		return nil, false
+	}
+	if value, ok := parseNumber(text); ok {
+		return &comparison{op: op, value: value}, true
+	}
+	if value, ok := parseDate(text); ok {
+		return &comparison{op: op, value: value, date: true}, true
+	}
+	return nil, false
+}
+
+// parseNumber parses a number with an optional size unit. The units are
+// powers of 1024 as in the output of `ls -h` and `du -h`.
+func parseNumber(str string) (float64, bool) {
+	match := _numberRegex.FindStringSubmatch(str)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	if len(match[2]) > 0 {
+		exp := strings.IndexByte("kmgtp", strings.ToLower(match[2])[0]) + 1
+		value *= math.Pow(1024, float64(exp))
+	}
+	return value, true
+}
+
+// parseDate parses an ISO 8601 date and returns it in seconds since epoch
+func parseDate(str string) (float64, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return float64(t.UnixNano()) / 1e9, true
+		}
+	}
+	return 0, false
+}
+
+// Match returns true if the field satisfies the condition. The fields that
+// cannot be parsed don't.
+func (c *comparison) Match(field string) bool {
+	field = strings.TrimSpace(field)
+	var value float64
+	var ok bool
+	if c.date {
+		value, ok = parseDate(field)
+	} else {
+		value, ok = parseNumber(field)
+	}
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case compareLt:
+		return value < c.value
+	case compareLe:
+		return value <= c.value
+	case compareGt:
+		return value > c.value
+	case compareGe:
+		return value >= c.value
+	}
+	return value == c.value
+}
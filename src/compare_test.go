@@ -0,0 +1,84 @@
+package fzf
+
+import (
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	for str, expected := range map[string]float64{
+		"100":    100,
+		"-1.5":   -1.5,
+		".5":     0.5,
+		"10K":    10 * 1024,
+		"1.5m":   1.5 * 1024 * 1024,
+		"2MiB":   2 * 1024 * 1024,
+		"1gb":    1024 * 1024 * 1024,
+		"512B":   512,
+		"+3":     3,
+		"2T":     2 * 1024 * 1024 * 1024 * 1024,
+		"1.0000": 1,
+	} {
+		if value, ok := parseNumber(str); !ok || value != expected {
+			t.Errorf("Expected %v for %q, got %v", expected, str, value)
+		}
+	}
+	for _, str := range []string{"", "K", "1X", "1KK", "inf", "NaN", "0x10", "1e3", "1,000", "1 K"} {
+		if _, ok := parseNumber(str); ok {
+			t.Errorf("Expected %q not to be a number", str)
+		}
+	}
+}
+
+func TestParseComparison(t *testing.T) {
+	for text, expected := range map[string]comparison{
+		">100":  {op: compareGt, value: 100},
+		">=1K":  {op: compareGe, value: 1024},
+		"<-1":   {op: compareLt, value: -1},
+		"<=0.5": {op: compareLe, value: 0.5},
+		"=42":   {op: compareEq, value: 42},
+		"<2024": {op: compareLt, value: 2024},
+	} {
+		if c, ok := parseComparison(text); !ok || *c != expected {
+			t.Errorf("Unexpected comparison for %q: %v", text, c)
+		}
+	}
+	for _, text := range []string{"100", ">", ">=", "<foo", "=>1", ">>1", "!>1"} {
+		if _, ok := parseComparison(text); ok {
+			t.Errorf("Expected %q not to be a comparison", text)
+		}
+	}
+	if c, ok := parseComparison("<=2024-01-01"); !ok || !c.date || c.op != compareLe {
+		t.Errorf("Expected date comparison: %v", c)
+	}
+}
+
+func TestComparisonMatch(t *testing.T) {
+	c, _ := parseComparison(">10M")
+	for field, expected := range map[string]bool{
+		"11M":      true,
+		"  20.5M ": true,
+		"1G":       true,
+		"10M":      false,
+		"9999K":    false,
+		"huge":     false,
+		"":         false,
+	} {
+		if c.Match(field) != expected {
+			t.Errorf("Expected %v for %q", expected, field)
+		}
+	}
+
+	c, _ = parseComparison("<=2024-01-01")
+	for field, expected := range map[string]bool{
+		"2023-12-31":          true,
+		"2024-01-01":          true,
+		"2024-01-01T00:00":    true,
+		"2024-01-01T00:00:01": false,
+		"2024-02-01":          false,
+		"20231231":            false,
+	} {
+		if c.Match(field) != expected {
+			t.Errorf("Expected %v for %q", expected, field)
+		}
+	}
+}
@@ -61,6 +61,7 @@ const (
 const (
 	EvtReadNew util.EventType = iota
 	EvtReadFin
+	EvtReadRemove
 	EvtSearchNew
 	EvtSearchProgress
 	EvtSearchFin
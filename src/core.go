@@ -2,6 +2,7 @@
 package fzf
 
 import (
+	"errors"
 	"fmt"
 	"maps"
 	"math"
@@ -9,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/junegunn/fzf/src/algo"
 	"github.com/junegunn/fzf/src/tui"
 	"github.com/junegunn/fzf/src/util"
 )
@@ -53,6 +55,11 @@ func buildItemTransformer(opts *Options) func(*Item) string {
 
 // Run starts fzf
 func Run(opts *Options) (int, error) {
+	// The input sources would silently take precedence over standard input
+	if len(opts.Sources) > 0 && opts.Input == nil && !util.IsTty(os.Stdin) {
+		return ExitError, errors.New("--source cannot be used with standard input")
+	}
+
 	if opts.Filter == nil {
 		if opts.useTmux() {
 			return runTmux(os.Args, opts)
@@ -81,6 +88,17 @@ func Run(opts *Options) (int, error) {
 
 	sort := opts.Sort > 0
 	sortCriteria = opts.Criteria
+	sortFields = opts.FieldCriteria
+	sortDelimiter = opts.Delimiter
+	names := make([]string, len(opts.Sources))
+	for idx, source := range opts.Sources {
+		names[idx] = source.name
+	}
+	setSourceNames(names)
+	if opts.Frecency != nil {
+		opts.Frecency.setKey(opts.Delimiter, opts.IdNth, opts.Ansi)
+		frecency = opts.Frecency
+	}
 
 	// Event channel
 	eventBox := util.NewEventBox()
@@ -152,11 +170,20 @@ func Run(opts *Options) (int, error) {
 		item.text.TrimTrailingWhitespaces(int(maxColorOffset))
 	}
 
+	// Frecency scores are computed once for each item
+	var frecencyCache *Frecency
+	if sortByFrecency() {
+		frecencyCache = frecency
+	}
+
 	var nthTransformer func([]Token, int32) string
 	if opts.WithNth == nil {
 		chunkList = NewChunkList(cache, func(item *Item, data []byte) bool {
 			item.text, item.colors = ansiProcessor(data)
 			item.text.Index = itemIndex
+			if frecencyCache != nil {
+				frecencyCache.add(item)
+			}
 			itemIndex++
 			return true
 		})
@@ -170,6 +197,9 @@ func Run(opts *Options) (int, error) {
 			}
 			item.text.Index = itemIndex
 			item.origText = &data
+			if frecencyCache != nil {
+				frecencyCache.add(item)
+			}
 			itemIndex++
 			return true
 		})
@@ -204,11 +234,20 @@ func Run(opts *Options) (int, error) {
 		reader = NewReader(func(data []byte) bool {
 			return chunkList.Push(data)
 		}, eventBox, executor, opts.ReadZero, opts.Filter == nil)
+		reader.setSourcePusher(chunkList.PushSource)
+		reader.setIndexPusher(chunkList.PushIndex)
 
 		ingestionStart = time.Now()
+		if opts.Filter != nil {
+			// No need to watch the directories in filter mode
+			opts.WalkerWatch = false
+		}
 		readyChan := make(chan bool)
-		go reader.ReadSource(opts.Input, opts.WalkerRoot, opts.WalkerOpts, opts.WalkerSkip, initialReload, initialEnv, readyChan)
+		go reader.ReadSource(opts.Input, opts.Sources, opts.WalkerRoot, opts.WalkerOpts, opts.WalkerSkip, opts.WalkerFilter, opts.WalkerFormat, opts.WalkerWatch, initialReload, initialEnv, readyChan)
 		<-readyChan
+		if terminal != nil && terminal.listener != nil {
+			go reader.appendItems(terminal.itemsChan)
+		}
 	}
 
 	// Matcher
@@ -256,6 +295,20 @@ func Run(opts *Options) (int, error) {
 			opts.Filter == nil, nth, opts.Delimiter, inputRevision, runes, denylistCopy, headerLines)
 	}
 	matcher := NewMatcher(cache, patternBuilder, sort, opts.Tac, eventBox, inputRevision, opts.Threads)
+	var removedItems []int32
+	if reader != nil && opts.WalkerWatch {
+		// Exclude the items of the files removed after the walk. Called by
+		// the reader before it pushes any item created after the removal.
+		reader.setRemover(func(indexes []int32) {
+			denyMutex.Lock()
+			for _, index := range indexes {
+				denylist[index] = struct{}{}
+			}
+			removedItems = append(removedItems, indexes...)
+			denyMutex.Unlock()
+			eventBox.Set(EvtReadRemove, nil)
+		})
+	}
 
 	// Filtering mode
 	if opts.Filter != nil {
@@ -272,23 +325,30 @@ func Run(opts *Options) (int, error) {
 		if streamingFilter {
 			slab := util.MakeSlab(slab16Size, slab32Size)
 			mutex := sync.Mutex{}
+			pusher := func(runes []byte, source uint8) bool {
+				// Held while building the item as the input sources are read
+				// concurrently
+				mutex.Lock()
+				defer mutex.Unlock()
+				item := Item{}
+				if chunkList.trans(&item, runes) {
+					item.text.SetSource(source)
+					if item.Index() < headerLines {
+						return false
+					}
+					if result, _, _ := pattern.MatchItem(&item, false, slab); result.item != nil {
+						opts.Printer(transformer(&item))
+						found = true
+					}
+				}
+				return false
+			}
 			reader := NewReader(
 				func(runes []byte) bool {
-					item := Item{}
-					if chunkList.trans(&item, runes) {
-						if item.Index() < headerLines {
-							return false
-						}
-						mutex.Lock()
-						if result, _, _ := pattern.MatchItem(&item, false, slab); result.item != nil {
-							opts.Printer(transformer(&item))
-							found = true
-						}
-						mutex.Unlock()
-					}
-					return false
+					return pusher(runes, 0)
 				}, eventBox, executor, opts.ReadZero, false)
-			reader.ReadSource(opts.Input, opts.WalkerRoot, opts.WalkerOpts, opts.WalkerSkip, initialReload, initialEnv, nil)
+			reader.setSourcePusher(pusher)
+			reader.ReadSource(opts.Input, opts.Sources, opts.WalkerRoot, opts.WalkerOpts, opts.WalkerSkip, opts.WalkerFilter, opts.WalkerFormat, opts.WalkerWatch, initialReload, initialEnv, nil)
 		} else {
 			eventBox.Unwatch(EvtReadNew)
 			eventBox.WaitFor(EvtReadFin)
@@ -334,6 +394,7 @@ func Run(opts *Options) (int, error) {
 					total.Seconds(),
 					totalItems, matchCount, selectivity,
 					float64(ingestionTime.Microseconds())/1000)
+				fmt.Printf("  scheme: %s  weights: %s\n", opts.Scheme, algo.CurrentWeights())
 				return ExitOk, nil
 			}
 
@@ -408,8 +469,12 @@ func Run(opts *Options) (int, error) {
 		reading = true
 		headerUpdated = false
 		startTick = ticks
+		reader.unwatch()
 		chunkList.Clear()
 		itemIndex = 0
+		if frecencyCache != nil {
+			frecencyCache.clear()
+		}
 		inputRevision.bumpMajor()
 		readyChan := make(chan bool)
 		go reader.restart(command, environ, readyChan)
@@ -480,6 +545,21 @@ func Run(opts *Options) (int, error) {
 						matcher.Reset(snapshot, input(), false, !reading, sort, snapshotRevision)
 					}
 
+				case EvtReadRemove:
+					// Items of the removed files are added to the denylist
+					denyMutex.Lock()
+					terminal.DeselectItems(removedItems)
+					removedItems = nil
+					denyMutex.Unlock()
+					// The cached results are still valid as the items in the
+					// denylist are excluded while matching the chunks
+					patternCache = make(map[string]*Pattern)
+					inputRevision.bumpMinor()
+					if !useSnapshot {
+						snapshotRevision = inputRevision
+					}
+					matcher.Reset(snapshot, input(), false, !reading, sort, snapshotRevision)
+
 				case EvtSearchNew:
 					var command *commandSpec
 					var environ []string
@@ -525,12 +605,14 @@ func Run(opts *Options) (int, error) {
 							chunkList.ForEachItem(func(item *Item) {
 								origBytes := *item.origText
 								savedIndex := item.Index()
+								savedSource := item.text.Source()
 								if newTransformer != nil {
 									transformItem(item, origBytes, newTransformer, savedIndex)
 								} else {
 									item.text, item.colors = ansiProcessor(origBytes)
 								}
 								item.text.Index = savedIndex
+								item.text.SetSource(savedSource)
 								item.transformed = nil
 							}, func() {
 								nthTransformer = newTransformer
@@ -0,0 +1,234 @@
+package fzf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/junegunn/fzf/src/algo"
+	"github.com/junegunn/fzf/src/util"
+)
+
+// Explanation describes how the score and the rank of an item are determined.
+// It is the value of {fzf:explain} and the explain field of GET / response.
+type Explanation struct {
+	Score    int                  `json:"score"`
+	Terms    [][]TermExplanation  `json:"terms"` // AND of OR sets
+	Tiebreak *TiebreakExplanation `json:"tiebreak,omitempty"`
+}
+
+// TermExplanation is the score of a search term. Chars is empty if the score
+// is not the sum of the scores of the matched characters (e.g. prefix-match).
+type TermExplanation struct {
+	Text    string              `json:"text"`
+	Type    string              `json:"type"`
+	Fields  string              `json:"fields,omitempty"`
+	Inverse bool                `json:"inverse,omitempty"`
+	Matched bool                `json:"matched"` // The term is satisfied
+	Score   int                 `json:"score"`
+	Chars   []algo.CharScore    `json:"chars,omitempty"`
+	Group   [][]TermExplanation `json:"group,omitempty"`
+}
+
+// TiebreakExplanation tells which sort criterion decided the order of the
+// item and its neighbor in the list
+type TiebreakExplanation struct {
+	Position  int    `json:"position"` // Position of the neighbor
+	Criterion string `json:"criterion"`
+}
+
+var termTypeNames = map[termType]string{
+	termFuzzy:         "fuzzy",
+	termExact:         "exact",
+	termExactBoundary: "exact-boundary",
+	termPrefix:        "prefix",
+	termSuffix:        "suffix",
+	termEqual:         "equal",
+	termApprox:        "approximate",
+	termRegex:         "regex",
+	termCompare:       "compare",
+	termGroup:         "group",
+}
+
+var criterionNames = map[criterion]string{
+	byScore:    "score",
+	byChunk:    "chunk",
+	byLength:   "length",
+	byBegin:    "begin",
+	byEnd:      "end",
+	byPathname: "pathname",
+	byFrecency: "frecency",
+	bySource:   "source",
+}
+
+// Explain returns the breakdown of the score of the item. Returns nil if the
+// item is not a match.
+func (p *Pattern) Explain(item *Item, slab *util.Slab) *Explanation {
+	_, score, _, matched := p.MatchScore(item, true, slab)
+	if !matched {
+		return nil
+	}
+	explanation := Explanation{Score: score}
+	if !p.extended {
+		offset, score, pos := p.basicMatch(item, true, slab)
+		typ := "fuzzy"
+		if !p.fuzzy {
+			typ = "exact"
+		}
+		term := TermExplanation{Text: string(p.text), Type: typ, Matched: true, Score: score}
+		term.Chars = explainChars(item, offset, score, pos)
+		explanation.Terms = [][]TermExplanation{{term}}
+		return &explanation
+	}
+
+	var input []Token
+	if len(p.nth) == 0 {
+		input = []Token{{text: &item.text, prefixLength: 0}}
+	} else {
+		input = p.transformInput(item)
+	}
+	var allTokens []Token
+	explanation.Terms = p.explainTermSets(p.termSets, item, input, &allTokens, slab)
+	return &explanation
+}
+
+func (p *Pattern) explainTermSets(termSets []termSet, item *Item, input []Token, allTokens *[]Token, slab *util.Slab) [][]TermExplanation {
+	sets := make([][]TermExplanation, len(termSets))
+	for idx, termSet := range termSets {
+		for _, term := range termSet {
+			explanation := TermExplanation{
+				Text:    string(term.text),
+				Type:    termTypeNames[term.typ],
+				Inverse: term.inv}
+			if len(term.nth) > 0 {
+				explanation.Fields = RangesToString(term.nth)
+			} else if term.source {
+				explanation.Fields = "source"
+			}
+			off, score := Offset{-1, -1}, 0
+			var pos *[]int
+			if term.typ == termGroup {
+				var offsets []Offset
+				offsets, score, _ = p.matchTermSets(term.group, item, input, allTokens, false, slab, nil)
+				if len(offsets) == len(term.group) {
+					off = spanOffsets(offsets)
+				}
+				explanation.Group = p.explainTermSets(term.group, item, input, allTokens, slab)
+			} else {
+				off, score, pos = p.matchTerm(term, item, input, allTokens, true, slab)
+			}
+			found := off[0] >= 0
+			explanation.Matched = found != term.inv
+			if found && !term.inv {
+				explanation.Score = score
+				if term.typ != termGroup && term.typ != termCompare && !term.source {
+					explanation.Chars = explainChars(item, off, score, pos)
+				}
+			}
+			sets[idx] = append(sets[idx], explanation)
+		}
+	}
+	return sets
+}
+
+// explainChars returns the scores of the matched characters if they add up
+// to the score of the term
+func explainChars(item *Item, off Offset, score int, pos *[]int) []algo.CharScore {
+	var positions []int
+	if pos != nil {
+		positions = append(positions, *pos...)
+	} else {
+		for idx := off[0]; idx < off[1]; idx++ {
+			positions = append(positions, int(idx))
+		}
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+	sort.Ints(positions)
+	chars, total := algo.ExplainScore(&item.text, positions)
+	if total != score {
+		return nil
+	}
+	return chars
+}
+
+// explainTiebreak returns the name of the first sort criterion that
+// distinguishes the two results. If none does, they are ordered by index.
+func explainTiebreak(r1 Result, r2 Result) string {
+	slot := len(r1.points) - 1
+	fieldIdx := 0
+	for _, criterion := range sortCriteria {
+		name := criterionNames[criterion]
+		differs := r1.points[slot] != r2.points[slot]
+		if criterion == byField {
+			// Field tiebreak takes two slots
+			field := sortFields[fieldIdx]
+			name = field.String()
+			fieldIdx++
+			slot--
+			differs = differs || r1.points[slot] != r2.points[slot] ||
+				field.compare(r1.item, r2.item) != 0
+		}
+		if differs {
+			return name
+		}
+		slot--
+	}
+	return "index"
+}
+
+// String returns the human-readable form of the explanation
+func (e *Explanation) String() string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("score: %d", e.Score))
+	lines = appendTermLines(lines, e.Terms, "")
+	if e.Tiebreak != nil {
+		lines = append(lines, fmt.Sprintf("tiebreak: %s (vs. position %d)", e.Tiebreak.Criterion, e.Tiebreak.Position))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func appendTermLines(lines []string, sets [][]TermExplanation, indent string) []string {
+	for _, set := range sets {
+		for idx, term := range set {
+			prefix := indent
+			if idx > 0 {
+				prefix += "| "
+			}
+			if term.Inverse {
+				prefix += "!"
+			}
+			if len(term.Fields) > 0 {
+				prefix += term.Fields + ":"
+			}
+			status := fmt.Sprintf("%d", term.Score)
+			if !term.Matched {
+				status = "not matched"
+			}
+			if len(term.Group) > 0 {
+				lines = append(lines, fmt.Sprintf("%s(group): %s", prefix, status))
+				lines = appendTermLines(lines, term.Group, indent+"  ")
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s%s %q: %s", prefix, term.Type, term.Text, status))
+			for _, char := range term.Chars {
+				line := fmt.Sprintf("%s  %q at %d: match %d, bonus %d", indent, char.Char, char.Index, char.Match, char.Bonus)
+				if len(char.Reason) > 0 {
+					line += " (" + char.Reason
+					if char.First {
+						line += ", first char"
+					}
+					line += ")"
+				} else if char.First {
+					line += " (first char)"
+				}
+				if char.Gap > 0 {
+					line += fmt.Sprintf(", gap %d: %d", char.Gap, char.Penalty)
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
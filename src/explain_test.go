@@ -0,0 +1,99 @@
+package fzf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/junegunn/fzf/src/algo"
+	"github.com/junegunn/fzf/src/util"
+)
+
+func TestExplain(t *testing.T) {
+	algo.Init("default")
+	pattern := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("fb | xyz ^foo !qux (bar | {1}>9)"))
+	item := Item{text: util.ToChars([]byte("foo-bar 10"))}
+	explanation := pattern.Explain(&item, slab)
+	if explanation == nil {
+		t.Fatal("Expected explanation")
+	}
+	_, score, _, _ := pattern.MatchScore(&item, false, slab)
+	if explanation.Score != score || len(explanation.Terms) != 4 {
+		t.Fatalf("Unexpected explanation: %v", explanation)
+	}
+
+	// Scores of the matched characters add up to the score of the term
+	fuzzy := explanation.Terms[0][0]
+	total := 0
+	for _, char := range fuzzy.Chars {
+		total += char.Match + char.Bonus + char.Penalty
+	}
+	if !fuzzy.Matched || fuzzy.Type != "fuzzy" || len(fuzzy.Chars) != 2 || total != fuzzy.Score ||
+		fuzzy.Chars[0].Reason != "boundary-white" || !fuzzy.Chars[0].First || fuzzy.Chars[1].Reason != "boundary" {
+		t.Errorf("Unexpected fuzzy term: %v", fuzzy)
+	}
+	if explanation.Terms[0][1].Matched || explanation.Terms[0][1].Text != "xyz" {
+		t.Errorf("Unexpected OR term: %v", explanation.Terms[0][1])
+	}
+	if prefix := explanation.Terms[1][0]; !prefix.Matched || prefix.Type != "prefix" || prefix.Score == 0 {
+		t.Errorf("Unexpected prefix term: %v", prefix)
+	}
+	if inverse := explanation.Terms[2][0]; !inverse.Matched || !inverse.Inverse || inverse.Score != 0 {
+		t.Errorf("Unexpected inverse term: %v", inverse)
+	}
+	group := explanation.Terms[3][0]
+	if group.Type != "group" || !group.Matched || len(group.Group) != 1 ||
+		group.Group[0][1].Type != "compare" || group.Group[0][1].Fields != "1" || group.Group[0][1].Matched {
+		t.Errorf("Unexpected group: %v", group)
+	}
+
+	str := explanation.String()
+	for _, line := range []string{
+		"score: ",
+		`fuzzy "fb": `,
+		`| fuzzy "xyz": not matched`,
+		`  "f" at 0: match 16, bonus 20 (boundary-white, first char)`,
+		`  "b" at 4: match 16, bonus 8 (boundary), gap 3: -5`,
+		`!exact "qux": 0`,
+		"(group): ",
+		`  | 1:compare ">9": not matched`,
+	} {
+		if !strings.Contains(str, line) {
+			t.Errorf("Expected %q in:\n%s", line, str)
+		}
+	}
+
+	// No explanation for a non-matching item
+	item = Item{text: util.ToChars([]byte("foo-bar qux"))}
+	if pattern.Explain(&item, slab) != nil {
+		t.Error("Expected no explanation")
+	}
+}
+
+func TestExplainTiebreak(t *testing.T) {
+	sortCriteria = []criterion{byScore, byLength}
+	item1 := Item{text: util.ToChars([]byte("foo"))}
+	item2 := Item{text: util.ToChars([]byte("foobar"))}
+	offsets := []Offset{{0, 1}}
+	if criterion := explainTiebreak(buildResult(&item1, offsets, 10), buildResult(&item2, offsets, 20)); criterion != "score" {
+		t.Errorf("Expected score, got %s", criterion)
+	}
+	if criterion := explainTiebreak(buildResult(&item1, offsets, 10), buildResult(&item2, offsets, 10)); criterion != "length" {
+		t.Errorf("Expected length, got %s", criterion)
+	}
+	if criterion := explainTiebreak(buildResult(&item1, offsets, 10), buildResult(&item1, offsets, 10)); criterion != "index" {
+		t.Errorf("Expected index, got %s", criterion)
+	}
+
+	// Field tiebreak takes two slots
+	sortCriteria = []criterion{byScore, byField, byLength}
+	sortFields = []fieldCriterion{{field: Range{1, 1}, numeric: true}}
+	item3 := Item{text: util.ToChars([]byte("10 foo"))}
+	item4 := Item{text: util.ToChars([]byte("10 foobar"))}
+	if criterion := explainTiebreak(buildResult(&item1, offsets, 10), buildResult(&item3, offsets, 10)); criterion != "field:1:numeric" {
+		t.Errorf("Expected field:1:numeric, got %s", criterion)
+	}
+	if criterion := explainTiebreak(buildResult(&item3, offsets, 10), buildResult(&item4, offsets, 10)); criterion != "length" {
+		t.Errorf("Expected length, got %s", criterion)
+	}
+}
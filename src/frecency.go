@@ -0,0 +1,203 @@
+package fzf
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum number of entries to keep in the frecency file
+const frecencyMax = 1000
+
+type frecencyEntry struct {
+	count int
+	last  int64 // Unix time of the last selection
+}
+
+// Frecency struct represents the store of the selected items for the
+// frecency tiebreak. Each line of the file is COUNT, TIMESTAMP, and KEY
+// separated by tabs.
+type Frecency struct {
+	path      string
+	entries   map[string]frecencyEntry
+	scores    map[string]int // Scores at startup; doesn't change while running
+	cache     sync.Map       // Nonzero scores of the items by index
+	delimiter Delimiter
+	nth       []Range // Fields for the key (--id-nth)
+	ansi      bool
+}
+
+// Frecency store of the current process. Never changes once fzf is started.
+var frecency *Frecency
+
+// sortByFrecency returns true if the frecency tiebreak is used. The result
+// list is sorted even when the query is empty.
+func sortByFrecency() bool {
+	return frecency != nil && slices.Contains(sortCriteria, byFrecency)
+}
+
+// NewFrecency returns the pointer to a new Frecency struct
+func NewFrecency(path string) (*Frecency, error) {
+	f := &Frecency{path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	f.scores = make(map[string]int, len(f.entries))
+	for key, entry := range f.entries {
+		f.scores[key] = entry.score(now)
+	}
+	return f, nil
+}
+
+func (f *Frecency) fmtError(e error) error {
+	if os.IsPermission(e) {
+		return errors.New("permission denied: " + f.path)
+	}
+	return errors.New("invalid frecency file: " + e.Error())
+}
+
+// load reads the entries from the file. Malformed lines are ignored.
+func (f *Frecency) load() error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		// If it doesn't exist, check if we can create a file with the name
+		if os.IsNotExist(err) {
+			data = []byte{}
+			if err := os.WriteFile(f.path, data, 0600); err != nil {
+				return f.fmtError(err)
+			}
+		} else {
+			return f.fmtError(err)
+		}
+	}
+	f.entries = make(map[string]frecencyEntry)
+	for _, line := range strings.Split(string(data), "\n") {
+		tokens := strings.SplitN(line, "\t", 3)
+		if len(tokens) < 3 || len(tokens[2]) == 0 {
+			continue
+		}
+		count, err := strconv.Atoi(tokens[0])
+		if err != nil || count < 1 {
+			continue
+		}
+		last, err := strconv.ParseInt(tokens[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		f.entries[tokens[2]] = frecencyEntry{count, last}
+	}
+	return nil
+}
+
+// score returns the frecency score of the entry. Items selected recently
+// weigh more than the ones selected long ago.
+func (e frecencyEntry) score(now int64) int {
+	age := now - e.last
+	switch {
+	case age < 60*60:
+		return e.count * 16
+	case age < 24*60*60:
+		return e.count * 8
+	case age < 7*24*60*60:
+		return e.count * 2
+	}
+	return e.count
+}
+
+// setKey sets how the key of an item is extracted
+func (f *Frecency) setKey(delimiter Delimiter, nth []Range, ansi bool) {
+	f.delimiter = delimiter
+	f.nth = nth
+	f.ansi = ansi
+}
+
+// keyFor returns the key of the item in the store. It's the whole line, or
+// the fields specified by --id-nth.
+func (f *Frecency) keyFor(item *Item) string {
+	str := item.AsString(f.ansi)
+	if len(f.nth) == 0 {
+		return str
+	}
+	return StripLastDelimiter(JoinTokens(Transform(Tokenize(str, f.delimiter), f.nth)), f.delimiter)
+}
+
+// score returns the frecency score of the item at startup
+func (f *Frecency) score(item *Item) int {
+	if len(f.scores) == 0 {
+		return 0
+	}
+	return f.scores[f.keyFor(item)]
+}
+
+// add computes the score of a new item and caches it by the index of the
+// item, so that the key of the item is not extracted again on every search
+func (f *Frecency) add(item *Item) {
+	if score := f.score(item); score > 0 {
+		f.cache.Store(item.Index(), score)
+	}
+}
+
+// cachedScore returns the score of the item cached by add
+func (f *Frecency) cachedScore(item *Item) int {
+	if score, ok := f.cache.Load(item.Index()); ok {
+		return score.(int)
+	}
+	return 0
+}
+
+// clear clears the cached scores as the indexes of the items are reused
+// after reload
+func (f *Frecency) clear() {
+	f.cache.Clear()
+}
+
+// record increments the counts of the selected items and updates the file.
+// The file is reloaded first so that the selections made by other fzf
+// processes in the meantime are not lost.
+func (f *Frecency) record(items []*Item) error {
+	if err := f.load(); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, item := range items {
+		key := f.keyFor(item)
+		// Keys with newline characters cannot be stored
+		if len(key) == 0 || strings.ContainsRune(key, '\n') {
+			continue
+		}
+		entry := f.entries[key]
+		f.entries[key] = frecencyEntry{entry.count + 1, now}
+	}
+
+	keys := make([]string, 0, len(f.entries))
+	for key := range f.entries {
+		keys = append(keys, key)
+	}
+	// Keep the entries with the highest scores
+	slices.SortFunc(keys, func(a, b string) int {
+		ea, eb := f.entries[a], f.entries[b]
+		if diff := cmp.Compare(eb.score(now), ea.score(now)); diff != 0 {
+			return diff
+		}
+		if diff := cmp.Compare(eb.last, ea.last); diff != 0 {
+			return diff
+		}
+		return strings.Compare(a, b)
+	})
+	if len(keys) > frecencyMax {
+		keys = keys[:frecencyMax]
+	}
+	var builder strings.Builder
+	for _, key := range keys {
+		entry := f.entries[key]
+		fmt.Fprintf(&builder, "%d\t%d\t%s\n", entry.count, entry.last, key)
+	}
+	return os.WriteFile(f.path, []byte(builder.String()), 0600)
+}
@@ -0,0 +1,72 @@
+package fzf
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+func TestFrecency(t *testing.T) {
+	if _, err := NewFrecency("/etc"); err == nil {
+		t.Error("Error expected for: /etc")
+	}
+
+	path := filepath.Join(t.TempDir(), "frecency")
+	now := time.Now().Unix()
+	os.WriteFile(path, []byte(strings.Join([]string{
+		"3\t" + strconv.FormatInt(now-2*24*60*60, 10) + "\tfoo",
+		"1\t" + strconv.FormatInt(now, 10) + "\tbar",
+		"invalid line",
+		"x\t0\tbaz",
+		"",
+	}, "\n")), 0600)
+
+	item := func(str string) *Item {
+		return &Item{text: util.ToChars([]byte(str))}
+	}
+	f, err := NewFrecency(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.entries) != 2 {
+		t.Errorf("Unexpected entries: %v", f.entries)
+	}
+	// Recently selected item wins over the frequently selected one
+	if f.score(item("foo")) != 6 || f.score(item("bar")) != 16 || f.score(item("baz")) != 0 {
+		t.Errorf("Unexpected scores: %v", f.scores)
+	}
+
+	f.record([]*Item{item("foo"), item("baz"), item("a\nb")})
+	f, _ = NewFrecency(path)
+	if f.entries["foo"].count != 4 || f.entries["baz"].count != 1 || len(f.entries) != 3 {
+		t.Errorf("Unexpected entries: %v", f.entries)
+	}
+	if f.score(item("foo")) != 64 {
+		t.Errorf("Unexpected score: %d", f.score(item("foo")))
+	}
+
+	// Scores are cached by the index of the item
+	foo, bar := item("foo"), item("bar")
+	foo.text.Index, bar.text.Index = 1, 2
+	f.add(foo)
+	f.add(bar)
+	foo.text = util.ToChars([]byte("bar"))
+	foo.text.Index = 1
+	if f.cachedScore(foo) != 64 || f.cachedScore(bar) != 16 {
+		t.Errorf("Unexpected cached scores: %d, %d", f.cachedScore(foo), f.cachedScore(bar))
+	}
+	if f.clear(); f.cachedScore(foo) != 0 {
+		t.Error("Cached scores should be cleared")
+	}
+
+	// Keys from --id-nth
+	f.setKey(delimiterRegexp(":"), []Range{{begin: 2, end: 2}}, false)
+	if key := f.keyFor(item("1:foo:bar")); key != "foo" {
+		t.Errorf("Unexpected key: %s", key)
+	}
+}
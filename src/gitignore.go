@@ -0,0 +1,368 @@
+package fzf
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Names of the ignore files in each directory in the order of precedence.
+// .gitignore only applies in git repositories.
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// ignorePattern is a line of an ignore file
+type ignorePattern struct {
+	segments []string // Slash-separated glob pattern
+	negate   bool     // !pattern
+	dirOnly  bool     // pattern/
+	anchored bool     // Relative to the directory of the ignore file
+}
+
+// ignoreFile is the patterns of an ignore file that apply to the entries
+// under dir (slash-separated). If the file is in an ancestor directory of the
+// walker root, prefix is the path of the root relative to the directory of
+// the file. The files of git don't apply beyond the repository.
+type ignoreFile struct {
+	dir      string
+	prefix   string
+	patterns []ignorePattern
+	git      bool
+}
+
+// ignoreDir is the ignore files for the entries in a directory
+type ignoreDir struct {
+	files []*ignoreFile
+	repo  bool // In a git repository
+}
+
+// gitIgnore keeps track of the ignore files while walking the directory
+// trees. It's safe for concurrent use.
+type gitIgnore struct {
+	mutex    sync.Mutex
+	dirs     map[string]ignoreDir
+	excludes string // Global excludes file of git
+}
+
+func newGitIgnore() *gitIgnore {
+	return &gitIgnore{dirs: make(map[string]ignoreDir), excludes: gitExcludesFile()}
+}
+
+// parseIgnorePattern parses a line of an ignore file. Returns false for
+// blank lines and comments.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if len(line) == 0 || line[0] == '#' {
+		return ignorePattern{}, false
+	}
+
+	pattern := ignorePattern{}
+	if line[0] == '!' {
+		pattern.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if len(line) == 0 {
+		return ignorePattern{}, false
+	}
+	// A pattern with a slash at the beginning or in the middle is relative to
+	// the directory of the ignore file
+	if strings.Contains(line, "/") {
+		pattern.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	pattern.segments = strings.Split(translateGlob(line), "/")
+	return pattern, true
+}
+
+// translateGlob translates the negated character classes of git ([!...]) to
+// the form that path.Match understands ([^...])
+func translateGlob(glob string) string {
+	if !strings.Contains(glob, "[!") {
+		return glob
+	}
+	var builder strings.Builder
+	for idx := 0; idx < len(glob); idx++ {
+		switch {
+		case glob[idx] == '\\' && idx+1 < len(glob):
+			builder.WriteString(glob[idx : idx+2])
+			idx++
+		case strings.HasPrefix(glob[idx:], "[!"):
+			builder.WriteString("[^")
+			idx++
+		default:
+			builder.WriteByte(glob[idx])
+		}
+	}
+	return builder.String()
+}
+
+// match returns true if the pattern matches the slash-separated path
+// relative to the directory of the ignore file
+func (p *ignorePattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		matched, _ := path.Match(p.segments[0], path.Base(rel))
+		return matched
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+// matchSegments matches the path segments against the glob segments. "**"
+// matches zero or more segments, or one or more at the end of the pattern.
+func matchSegments(globs []string, segments []string) bool {
+	for len(globs) > 0 {
+		if globs[0] == "**" {
+			if len(globs) == 1 {
+				return len(segments) > 0
+			}
+			for idx := range len(segments) + 1 {
+				if matchSegments(globs[1:], segments[idx:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(globs[0], segments[0]); !matched {
+			return false
+		}
+		globs, segments = globs[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// readIgnoreFile reads the patterns in the file. Returns nil if the file
+// doesn't exist or has no patterns.
+func readIgnoreFile(file string, dir string, prefix string, git bool) *ignoreFile {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	patterns := []ignorePattern{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if pattern, ok := parseIgnorePattern(scanner.Text()); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	return &ignoreFile{dir: dir, prefix: prefix, patterns: patterns, git: git}
+}
+
+// isRepository returns true if the directory is the root of a git
+// repository. .git is a file in worktrees and submodules.
+func isRepository(osDir string) bool {
+	_, err := os.Stat(filepath.Join(osDir, ".git"))
+	return err == nil
+}
+
+// readIgnoreFiles appends the ignore files in the directory to the list in
+// the order of precedence. .gitignore is only read in a repository.
+func readIgnoreFiles(files []*ignoreFile, osDir string, dir string, prefix string, repo bool) []*ignoreFile {
+	for _, name := range ignoreFileNames {
+		git := name == ".gitignore"
+		if git && !repo {
+			continue
+		}
+		if file := readIgnoreFile(filepath.Join(osDir, name), dir, prefix, git); file != nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// enterRepository returns the list of the ignore files at the root of a git
+// repository. The files of git from the outside are replaced with the global
+// excludes file and .git/info/exclude of the repository.
+func (g *gitIgnore) enterRepository(parent []*ignoreFile, osDir string, dir string, prefix string) []*ignoreFile {
+	files := []*ignoreFile{}
+	for _, file := range parent {
+		if !file.git {
+			files = append(files, file)
+		}
+	}
+	for _, path := range []string{g.excludes, filepath.Join(osDir, ".git", "info", "exclude")} {
+		if len(path) == 0 {
+			continue
+		}
+		if file := readIgnoreFile(path, dir, prefix, true); file != nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// ignoreKey returns the slash-separated form of the path of the directory
+func ignoreKey(dir string) string {
+	return filepath.ToSlash(filepath.Clean(dir))
+}
+
+// enterRoot loads the ignore files that apply to the walker root. In a git
+// repository, they are the global excludes file of git, and the ignore files
+// in the root and its ancestor directories up to the root of the repository.
+// Otherwise, only the .ignore files in the root.
+func (g *gitIgnore) enterRoot(root string) {
+	dir := ignoreKey(root)
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+
+	// Directories from the root of the repository to the walker root
+	ancestors := []string{abs}
+	repo := true
+	for current := abs; ; {
+		if isRepository(current) {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			ancestors = ancestors[:1]
+			repo = false
+			break
+		}
+		current = parent
+		ancestors = append(ancestors, current)
+	}
+
+	prefixOf := func(ancestor string) string {
+		if rel, err := filepath.Rel(ancestor, abs); err == nil && rel != "." {
+			return filepath.ToSlash(rel) + "/"
+		}
+		return ""
+	}
+	files := []*ignoreFile{}
+	if repo {
+		top := ancestors[len(ancestors)-1]
+		files = g.enterRepository(files, top, dir, prefixOf(top))
+	}
+	for idx := len(ancestors) - 1; idx >= 0; idx-- {
+		files = readIgnoreFiles(files, ancestors[idx], dir, prefixOf(ancestors[idx]), repo)
+	}
+
+	g.mutex.Lock()
+	g.dirs[dir] = ignoreDir{files, repo}
+	g.mutex.Unlock()
+}
+
+// enter loads the ignore files in the directory found while walking. A
+// nested git repository doesn't inherit the files of git from the outside.
+func (g *gitIgnore) enter(osDir string) {
+	dir := ignoreKey(osDir)
+	g.mutex.Lock()
+	if _, found := g.dirs[dir]; found {
+		// Walker root
+		g.mutex.Unlock()
+		return
+	}
+	parent := g.dirs[ignoreKey(filepath.Dir(osDir))]
+	g.mutex.Unlock()
+
+	files := parent.files[:len(parent.files):len(parent.files)]
+	repo := parent.repo
+	if isRepository(osDir) {
+		files = g.enterRepository(files, osDir, dir, "")
+		repo = true
+	}
+	files = readIgnoreFiles(files, osDir, dir, "", repo)
+
+	g.mutex.Lock()
+	g.dirs[dir] = ignoreDir{files, repo}
+	g.mutex.Unlock()
+}
+
+// ignored returns true if the entry is excluded by the ignore files. The
+// last matching pattern decides.
+func (g *gitIgnore) ignored(entry string, isDir bool) bool {
+	g.mutex.Lock()
+	files := g.dirs[ignoreKey(filepath.Dir(entry))].files
+	g.mutex.Unlock()
+
+	entry = filepath.ToSlash(entry)
+	ignored := false
+	for _, file := range files {
+		rel := entry
+		if file.dir != "." {
+			rel = strings.TrimPrefix(rel, strings.TrimSuffix(file.dir, "/")+"/")
+		}
+		rel = file.prefix + rel
+		for idx := range file.patterns {
+			if file.patterns[idx].match(rel, isDir) {
+				ignored = !file.patterns[idx].negate
+			}
+		}
+	}
+	return ignored
+}
+
+// gitExcludesFile returns the path of the global excludes file of git. It's
+// core.excludesFile in the user's git configuration, or git/ignore in the
+// XDG config directory by default.
+func gitExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if len(configHome) == 0 && len(home) > 0 {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	excludes := ""
+	if len(configHome) > 0 {
+		excludes = filepath.Join(configHome, "git", "ignore")
+	}
+	// ~/.gitconfig takes precedence over the XDG one
+	for _, config := range []string{filepath.Join(configHome, "git", "config"), filepath.Join(home, ".gitconfig")} {
+		if value := readGitConfig(config, "core", "excludesfile"); len(value) > 0 {
+			excludes = value
+		}
+	}
+	if strings.HasPrefix(excludes, "~/") && len(home) > 0 {
+		excludes = filepath.Join(home, excludes[2:])
+	}
+	return excludes
+}
+
+// readGitConfig returns the value of the key in the section of the git
+// configuration file. Includes and subsections are not supported.
+func readGitConfig(file string, section string, key string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	value := ""
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			current = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+		name, val, found := strings.Cut(line, "=")
+		if found && current == section && strings.ToLower(strings.TrimSpace(name)) == key {
+			value = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+	}
+	return value
+}
@@ -0,0 +1,190 @@
+package fzf
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/junegunn/fzf/src/util"
+)
+
+func TestIgnorePattern(t *testing.T) {
+	for _, tc := range []struct {
+		line  string
+		rel   string
+		isDir bool
+		match bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "foo/a.log", false, true},
+		{"*.log", "a.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "foo/build", true, true},
+		{"build/", "build", false, false},
+		{"/build", "build", false, true},
+		{"/build", "foo/build", false, false},
+		{"foo/bar", "foo/bar", false, true},
+		{"foo/bar", "baz/foo/bar", false, false},
+		{"**/bar", "bar", false, true},
+		{"**/bar", "foo/baz/bar", false, true},
+		{"foo/**", "foo/bar/baz", false, true},
+		{"foo/**", "foo", true, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"trailing  ", "trailing", false, true},
+		{"\\#hash", "#hash", false, true},
+		{"[!a]*.log", "b.log", false, true},
+		{"[!a]*.log", "a.log", false, false},
+		{"foo/[!a]", "foo/b", false, true},
+		{"\\[!a]", "[!a]", false, true},
+		{"\\[!a]", "b", false, false},
+	} {
+		pattern, ok := parseIgnorePattern(tc.line)
+		if !ok {
+			t.Errorf("%q should be a pattern", tc.line)
+			continue
+		}
+		if pattern.match(tc.rel, tc.isDir) != tc.match {
+			t.Errorf("%q matching %q (dir: %v) should be %v", tc.line, tc.rel, tc.isDir, tc.match)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if _, ok := parseIgnorePattern(line); ok {
+			t.Errorf("%q should not be a pattern", line)
+		}
+	}
+	if pattern, _ := parseIgnorePattern("!keep.log"); !pattern.negate {
+		t.Error("!keep.log should be negated")
+	}
+}
+
+func TestReadFilesGitIgnore(t *testing.T) {
+	// Don't read the global excludes file of the user
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	os.MkdirAll(filepath.Join(home, ".config", "git"), 0700)
+	os.WriteFile(filepath.Join(home, ".config", "git", "ignore"), []byte("*.swp\n"), 0600)
+
+	root := t.TempDir()
+	files := map[string]string{
+		".git/info/exclude":   "excluded\n",
+		".gitignore":          "*.log\n!keep.log\n/top\nbuild/\n",
+		"a.txt":               "",
+		"a.log":               "",
+		"keep.log":            "",
+		"a.swp":               "",
+		"excluded":            "",
+		"top":                 "",
+		"sub/top":             "",
+		"sub/b.log":           "",
+		"sub/build/c.txt":     "",
+		"sub/.ignore":         "*.txt\n",
+		"sub/d.txt":           "",
+		"sub/nested/e.go":     "",
+		"sub/nested/f.txt":    "",
+		"sub/nested/.ignore":  "!f.txt\n",
+		"other/build":         "",
+		"other/.gitignore":    "/g.go\n",
+		"other/g.go":          "",
+		"other/deeper/g.go":   "",
+		"other/deeper/h.html": "",
+		".ignore":             "*.bak\n",
+		"z.bak":               "",
+		"repo/.git/HEAD":      "",
+		"repo/.gitignore":     "*.tmp\n",
+		"repo/a.log":          "",
+		"repo/b.swp":          "",
+		"repo/x.tmp":          "",
+		"repo/y.bak":          "",
+	}
+	create := func(root string, files map[string]string) {
+		for name, content := range files {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			os.MkdirAll(filepath.Dir(path), 0700)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	create(root, files)
+
+	walk := func(root string) []string {
+		var mutex sync.Mutex
+		paths := []string{}
+		reader := NewReader(func(s []byte) bool {
+			mutex.Lock()
+			paths = append(paths, filepath.ToSlash(string(s)))
+			mutex.Unlock()
+			return true
+		}, util.NewEventBox(), util.NewExecutor(""), false, true)
+		reader.readFiles([]string{root}, walkerOpts{file: true, hidden: true, gitignore: true}, []string{".git"}, walkerFilter{}, nil, false)
+		slices.Sort(paths)
+		return paths
+	}
+
+	paths := walk(root)
+	prefix := filepath.ToSlash(root) + "/"
+	for idx := range paths {
+		paths[idx] = strings.TrimPrefix(paths[idx], prefix)
+	}
+	expected := []string{
+		".gitignore",
+		".ignore",
+		"a.txt",
+		"keep.log",
+		"other/.gitignore",
+		"other/build",
+		"other/deeper/g.go",
+		"other/deeper/h.html",
+		"repo/.gitignore",
+		"repo/a.log",
+		"sub/.ignore",
+		"sub/nested/.ignore",
+		"sub/nested/e.go",
+		"sub/nested/f.txt",
+		"sub/top",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, paths)
+	}
+
+	// The ignore files in the ancestor directories of the walker root apply
+	paths = walk(filepath.Join(root, "sub"))
+	for idx := range paths {
+		paths[idx] = strings.TrimPrefix(paths[idx], prefix)
+	}
+	expected = []string{
+		"sub/.ignore",
+		"sub/nested/.ignore",
+		"sub/nested/e.go",
+		"sub/nested/f.txt",
+		"sub/top",
+	}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, paths)
+	}
+
+	// Only .ignore files apply outside git repositories
+	root = t.TempDir()
+	create(root, map[string]string{
+		".gitignore": "*.log\n",
+		".ignore":    "*.bak\n",
+		"a.log":      "",
+		"b.bak":      "",
+		"c.swp":      "",
+	})
+	paths = walk(root)
+	prefix = filepath.ToSlash(root) + "/"
+	for idx := range paths {
+		paths[idx] = strings.TrimPrefix(paths[idx], prefix)
+	}
+	expected = []string{".gitignore", ".ignore", "a.log", "c.swp"}
+	if !slices.Equal(paths, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, paths)
+	}
+}
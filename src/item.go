@@ -26,6 +26,42 @@ func (item *Item) Index() int32 {
 	return item.text.Index
 }
 
+// Names of the input sources (--source). Never changes once fzf is started.
+var sourceNames []string
+
+// SourceName returns the name of the input source of the Item, or an empty
+// string if it's not from any of them
+func (item *Item) SourceName() string {
+	if source := int(item.text.Source()); source > 0 && source <= len(sourceNames) {
+		return sourceNames[source-1]
+	}
+	return ""
+}
+
+// Names of the input sources matched against the source terms, indexed by
+// the 1-based index of the source. The first one is an empty name for the
+// items not from any of them. Shared by the matcher threads, so the trim
+// lengths are computed beforehand.
+var sourceChars []util.Chars
+
+// setSourceNames sets the names of the input sources
+func setSourceNames(names []string) {
+	sourceNames = names
+	sourceChars = make([]util.Chars, len(names)+1)
+	for idx, name := range append([]string{""}, names...) {
+		sourceChars[idx] = util.ToChars([]byte(name))
+		sourceChars[idx].TrimLength()
+	}
+}
+
+// sourceNameChars returns the name of the input source of the Item as Chars
+func (item *Item) sourceNameChars() *util.Chars {
+	if source := int(item.text.Source()); source < len(sourceChars) {
+		return &sourceChars[source]
+	}
+	return &sourceChars[0]
+}
+
 var minItem = Item{text: util.Chars{Index: math.MinInt32}}
 
 func (item *Item) TrimLength() uint16 {
@@ -164,7 +164,7 @@ func (m *Matcher) scan(request MatchRequest) MatchResult {
 	}
 	pattern := request.pattern
 	passMerger := PassMerger(&request.chunks, m.tac, request.revision, pattern.startIndex)
-	if pattern.IsEmpty() {
+	if pattern.IsEmpty() && !(m.sort && sortByFrecency()) {
 		return MatchResult{passMerger, passMerger, false}
 	}
 
@@ -7,9 +7,11 @@ import (
 	"math"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/junegunn/fzf/src/algo"
 	"github.com/junegunn/fzf/src/tui"
@@ -37,7 +39,7 @@ Usage: fzf [options]
     -i, --ignore-case        Case-insensitive match
     +i, --no-ignore-case     Case-sensitive match
         --smart-case         Smart-case match (default)
-    --scheme=SCHEME          Scoring scheme [default|path|history]
+    --scheme=SCHEME          Scoring scheme [default|path|history|custom:WEIGHTS]
     -n, --nth=N[,..]         Comma-separated list of field index expressions
                              for limiting search scope. Each can be a non-zero
                              integer or a range expression ([BEGIN]..[END]).
@@ -51,13 +53,16 @@ Usage: fzf [options]
     --disabled               Do not perform search
     --tiebreak=CRI[,..]      Comma-separated list of sort criteria to apply
                              when the scores are tied
-                             [length|chunk|pathname|begin|end|index] (default: length)
+                             [length|chunk|pathname|begin|end|frecency|source|index]
+                             or field:N[:numeric][:reverse] (default: length)
 
   INPUT/OUTPUT
     --read0                  Read input delimited by ASCII NUL characters
     --print0                 Print output delimited by ASCII NUL characters
     --ansi                   Enable processing of ANSI color codes
     --sync                   Synchronous search for multi-staged filtering
+    --source=NAME:COMMAND    Read input from the command and tag the items with
+                             the name (repeatable; commands run concurrently)
 
   GLOBAL STYLE
     --style=PRESET           Apply a style preset [default|minimal|full[:BORDER_STYLE]
@@ -217,16 +222,31 @@ Usage: fzf [options]
                              (To allow remote process execution, use --listen-unsafe)
     --listen=SOCKET_PATH     Start HTTP server to receive actions via Unix domain socket
                              (Path should end with .sock)
+    --listen-actions=ACTIONS Comma-separated list of actions allowed via HTTP server
+    --remote[=ADDR] ACTIONS  Send actions to the HTTP server of a running fzf
+                             (default: $FZF_SOCK or $FZF_PORT)
+    --remote-status[=ADDR]   Print the state of a running fzf in JSON format
 
   DIRECTORY TRAVERSAL        (Only used when $FZF_DEFAULT_COMMAND is not set)
-    --walker=OPTS            [file][,dir][,follow][,hidden] (default: file,follow,hidden)
+    --walker=OPTS            [file][,dir][,follow][,hidden][,gitignore]
+                             (default: file,follow,hidden)
     --walker-root=DIR [...]  List of directories to walk (default: .)
     --walker-skip=DIRS       Comma-separated list of directory names to skip
                              (default: .git,node_modules)
+    --walker-max-depth=N     Maximum depth of the directory walk (default: 0, unlimited)
+    --walker-ext=EXTS        Comma-separated list of file extensions to include
+    --walker-min-size=SIZE   Skip files smaller than SIZE (e.g. 10K)
+    --walker-max-size=SIZE   Skip files larger than SIZE (e.g. 1M)
+    --walker-newer=AGE       Skip files not modified within AGE (e.g. 2d)
+                             or since the date (e.g. 2024-01-01)
+    --walker-format=FORMAT   Format of the walker output (e.g. '{size}\t{path}')
+                             Fields: {path} {name} {type} {size} {hsize} {mtime} {mode}
+    --walker-watch           Update the list as files are created or deleted (Linux only)
 
   HISTORY
     --history=FILE           File to store fzf search history (*not* shell command history)
     --history-size=N         Maximum number of entries to keep in the file (default: 1000)
+    --frecency=FILE          File to record the selected items for frecency tiebreak
 
   SHELL INTEGRATION
     --bash                   Print script to set up Bash shell integration
@@ -244,6 +264,7 @@ Usage: fzf [options]
     FZF_DEFAULT_OPTS         Default options (e.g. '--layout=reverse --info=inline')
     FZF_DEFAULT_OPTS_FILE    Location of the file to read default options from
     FZF_API_KEY              X-API-Key header for HTTP server (--listen)
+    FZF_API_KEY_READONLY     X-API-Key header for read-only access to HTTP server
 
 `
 
@@ -272,8 +293,29 @@ const (
 	byBegin
 	byEnd
 	byPathname
+	byFrecency
+	byField
+	bySource
 )
 
+// fieldCriterion is the spec of a field tiebreak (e.g. field:3:numeric)
+type fieldCriterion struct {
+	field   Range
+	numeric bool
+	reverse bool
+}
+
+func (c fieldCriterion) String() string {
+	str := "field:" + RangesToString([]Range{c.field})
+	if c.numeric {
+		str += ":numeric"
+	}
+	if c.reverse {
+		str += ":reverse"
+	}
+	return str
+}
+
 type heightSpec struct {
 	size    float64
 	percent bool
@@ -569,10 +611,28 @@ func firstLine(s string) string {
 }
 
 type walkerOpts struct {
-	file   bool
-	dir    bool
-	hidden bool
-	follow bool
+	file      bool
+	dir       bool
+	hidden    bool
+	follow    bool
+	gitignore bool
+}
+
+// walkerFilter is the conditions of the entries reported by the walker.
+// Except for maxDepth, they only apply to files.
+type walkerFilter struct {
+	maxDepth int       // 0 means no limit
+	exts     []string  // Lowercase extensions without the leading dot
+	minSize  int64     // In bytes
+	maxSize  int64     // In bytes; 0 means no limit
+	newer    time.Time // Minimum modification time
+}
+
+// inputSource is a command whose output items are tagged with the name
+// (--source)
+type inputSource struct {
+	name    string
+	command string
 }
 
 // Options stores the values of command-line options
@@ -610,6 +670,7 @@ type Options struct {
 	Tac               bool
 	Tail              int
 	Criteria          []criterion
+	FieldCriteria     []fieldCriterion // Specs of byField criteria in order
 	Multi             int
 	Ansi              bool
 	Mouse             bool
@@ -658,6 +719,7 @@ type Options struct {
 	PrintSep          string
 	Sync              bool
 	History           *History
+	Frecency          *Frecency
 	Header            []string
 	HeaderLines       int
 	HeaderFirst       bool
@@ -685,11 +747,18 @@ type Options struct {
 	Tabstop           int
 	WithShell         string
 	ListenAddr        *listenAddress
+	Remote            *remoteRequest
+	ListenActions     map[actionType]bool
+	ListenItems       bool
 	Unsafe            bool
 	ClearOnExit       bool
 	WalkerOpts        walkerOpts
 	WalkerRoot        []string
 	WalkerSkip        []string
+	WalkerFilter      walkerFilter
+	WalkerFormat      walkerFormat // Empty means the path only
+	WalkerWatch       bool
+	Sources           []inputSource
 	Version           bool
 	Help              bool
 	Threads           int
@@ -956,8 +1025,10 @@ func parseAlgo(str string) (algo.Algo, error) {
 		return algo.FuzzyMatchV1, nil
 	case "v2":
 		return algo.FuzzyMatchV2, nil
+	case "initials":
+		return algo.InitialsMatch, nil
 	}
-	return nil, errors.New("invalid algorithm (expected: v1 or v2)")
+	return nil, errors.New("invalid algorithm (expected: v1, v2, or initials)")
 }
 
 func parseBorder(str string, optional bool) (tui.BorderShape, error) {
@@ -1343,17 +1414,54 @@ func parseScheme(str string) (string, []criterion, error) {
 	case "default":
 		return str, []criterion{byScore, byLength}, nil
 	}
-	return str, nil, errors.New("invalid scoring scheme: " + str + " (expected: default|path|history)")
+	if strings.HasPrefix(str, algo.CustomSchemePrefix) {
+		if _, err := algo.ParseWeights(str[len(algo.CustomSchemePrefix):]); err != nil {
+			return str, nil, errors.New("invalid scoring scheme: " + err.Error())
+		}
+		return str, []criterion{byScore, byLength}, nil
+	}
+	return str, nil, errors.New("invalid scoring scheme: " + str + " (expected: default|path|history|custom:WEIGHTS)")
+}
+
+// parseFieldCriterion parses the field tiebreak in FIELD[:numeric][:reverse]
+// format
+func parseFieldCriterion(str string) (fieldCriterion, error) {
+	tokens := strings.Split(str, ":")
+	r, ok := ParseRange(&tokens[0])
+	if !ok || len(tokens[0]) == 0 {
+		return fieldCriterion{}, errors.New("invalid field index expression: " + tokens[0])
+	}
+	spec := fieldCriterion{field: r}
+	for _, modifier := range tokens[1:] {
+		switch modifier {
+		case "numeric":
+			if spec.numeric {
+				return spec, errors.New("duplicate modifier: numeric")
+			}
+			spec.numeric = true
+		case "reverse":
+			if spec.reverse {
+				return spec, errors.New("duplicate modifier: reverse")
+			}
+			spec.reverse = true
+		default:
+			return spec, errors.New("invalid field tiebreak modifier: " + modifier + " (expected: numeric|reverse)")
+		}
+	}
+	return spec, nil
 }
 
-func parseTiebreak(str string) ([]criterion, error) {
+func parseTiebreak(str string) ([]criterion, []fieldCriterion, error) {
 	criteria := []criterion{byScore}
+	fields := []fieldCriterion{}
 	hasIndex := false
 	hasChunk := false
 	hasLength := false
 	hasBegin := false
 	hasEnd := false
 	hasPathname := false
+	hasFrecency := false
+	hasSource := false
 	check := func(notExpected *bool, name string) error {
 		if *notExpected {
 			return errors.New("duplicate sort criteria: " + name)
@@ -1368,41 +1476,87 @@ func parseTiebreak(str string) ([]criterion, error) {
 		switch str {
 		case "index":
 			if err := check(&hasIndex, "index"); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		case "chunk":
 			if err := check(&hasChunk, "chunk"); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			criteria = append(criteria, byChunk)
 		case "pathname":
 			if err := check(&hasPathname, "pathname"); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			criteria = append(criteria, byPathname)
 		case "length":
 			if err := check(&hasLength, "length"); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			criteria = append(criteria, byLength)
 		case "begin":
 			if err := check(&hasBegin, "begin"); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			criteria = append(criteria, byBegin)
 		case "end":
 			if err := check(&hasEnd, "end"); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			criteria = append(criteria, byEnd)
+		case "frecency":
+			if err := check(&hasFrecency, "frecency"); err != nil {
+				return nil, nil, err
+			}
+			criteria = append(criteria, byFrecency)
+		case "source":
+			if err := check(&hasSource, "source"); err != nil {
+				return nil, nil, err
+			}
+			criteria = append(criteria, bySource)
 		default:
-			return nil, errors.New("invalid sort criterion: " + str)
+			if !strings.HasPrefix(str, "field:") {
+				return nil, nil, errors.New("invalid sort criterion: " + str)
+			}
+			if hasIndex {
+				return nil, nil, errors.New("index should be the last criterion")
+			}
+			spec, err := parseFieldCriterion(str[len("field:"):])
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, field := range fields {
+				if field.field == spec.field {
+					return nil, nil, errors.New("duplicate sort criteria: " + str)
+				}
+			}
+			criteria = append(criteria, byField)
+			fields = append(fields, spec)
 		}
 	}
-	if len(criteria) > 4 {
-		return nil, errors.New("at most 3 tiebreaks are allowed: " + str)
+	// A field tiebreak takes two rank points
+	if len(criteria)+len(fields) > 4 {
+		if len(fields) > 0 {
+			return nil, nil, errors.New("at most 3 tiebreaks are allowed, and a field tiebreak counts as two: " + str)
+		}
+		return nil, nil, errors.New("at most 3 tiebreaks are allowed: " + str)
 	}
-	return criteria, nil
+	return criteria, fields, nil
+}
+
+// parseInputSource parses NAME:COMMAND of --source. The name can't contain
+// whitespace so that it can be used in a search term (source:NAME).
+func parseInputSource(str string) (inputSource, error) {
+	name, command, found := strings.Cut(str, ":")
+	if !found || len(name) == 0 {
+		return inputSource{}, errors.New("invalid input source: " + str + " (expected: NAME:COMMAND)")
+	}
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return inputSource{}, errors.New("input source name should not contain whitespace: " + name)
+	}
+	if len(strings.TrimSpace(command)) == 0 {
+		return inputSource{}, errors.New("command required for input source: " + name)
+	}
+	return inputSource{name, command}, nil
 }
 
 func dupeTheme(theme *tui.ColorTheme) *tui.ColorTheme {
@@ -1639,6 +1793,8 @@ func parseWalkerOpts(str string) (walkerOpts, error) {
 			opts.hidden = true
 		case "follow":
 			opts.follow = true
+		case "gitignore":
+			opts.gitignore = true
 		case "":
 			// Ignored
 		default:
@@ -1651,6 +1807,86 @@ func parseWalkerOpts(str string) (walkerOpts, error) {
 	return opts, nil
 }
 
+// walkerFormatFields are the placeholders of --walker-format
+var walkerFormatFields = []string{"path", "name", "type", "size", "hsize", "mtime", "mode"}
+
+// walkerFormatPart is a literal text or a placeholder of --walker-format
+type walkerFormatPart struct {
+	text  string
+	field string
+}
+
+// walkerFormat is the template of the lines of the walker output
+type walkerFormat []walkerFormatPart
+
+// parseWalkerFormat parses the template of the walker output. \t is
+// replaced with a tab character as in --delimiter.
+func parseWalkerFormat(str string) (walkerFormat, error) {
+	str = strings.ReplaceAll(str, "\\t", "\t")
+	format := walkerFormat{}
+	for len(str) > 0 {
+		begin := strings.IndexByte(str, '{')
+		end := strings.IndexByte(str[max(begin, 0):], '}') + max(begin, 0)
+		if begin < 0 || end < begin {
+			format = append(format, walkerFormatPart{text: str})
+			break
+		}
+		if begin > 0 {
+			format = append(format, walkerFormatPart{text: str[:begin]})
+		}
+		field := str[begin+1 : end]
+		if !slices.Contains(walkerFormatFields, field) {
+			return nil, fmt.Errorf("invalid walker format field: {%s} (expected: {%s})", field, strings.Join(walkerFormatFields, "}|{"))
+		}
+		format = append(format, walkerFormatPart{field: field})
+		str = str[end+1:]
+	}
+	return format, nil
+}
+
+// parseWalkerExts parses the comma-separated list of file extensions
+func parseWalkerExts(str string) []string {
+	exts := []string{}
+	for _, ext := range strings.Split(str, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); len(ext) > 0 {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// parseWalkerSize parses a file size with an optional unit (e.g. 100, 10K, 2MiB)
+func parseWalkerSize(str string) (int64, error) {
+	size, ok := parseNumber(str)
+	if !ok || size < 0 {
+		return 0, errors.New("invalid file size: " + str + " (expected: SIZE[K|M|G|T])")
+	}
+	return int64(size), nil
+}
+
+// parseWalkerNewer parses the age of the files to include (e.g. 30m, 2d, 1w)
+// or an ISO 8601 date, and returns the minimum modification time
+func parseWalkerNewer(str string, now time.Time) (time.Time, error) {
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	if len(str) > 1 {
+		if unit, ok := units[str[len(str)-1]]; ok {
+			if num, err := strconv.Atoi(str[:len(str)-1]); err == nil && num >= 0 {
+				return now.Add(-time.Duration(num) * unit), nil
+			}
+		}
+	}
+	if seconds, ok := parseDate(str); ok {
+		return time.Unix(0, int64(seconds*1e9)), nil
+	}
+	return time.Time{}, errors.New("invalid age: " + str + " (expected: NUMBER[s|m|h|d|w] or YYYY-MM-DD)")
+}
+
 var (
 	argActionRegexp  *regexp.Regexp
 	splitRegexp      *regexp.Regexp
@@ -2562,6 +2798,7 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 		opts.Help = false
 		opts.Version = false
 		opts.Man = false
+		opts.Remote = nil
 	}
 
 	startIndex := *index
@@ -2736,7 +2973,7 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 		case "--no-literal":
 			opts.Normalize = true
 		case "--algo":
-			str, err := nextString("algorithm required (v1|v2)")
+			str, err := nextString("algorithm required (v1|v2|initials)")
 			if err != nil {
 				return err
 			}
@@ -2744,7 +2981,7 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 				return err
 			}
 		case "--scheme":
-			str, err := nextString("scoring scheme required (default|path|history)")
+			str, err := nextString("scoring scheme required (default|path|history|custom:WEIGHTS)")
 			if err != nil {
 				return err
 			}
@@ -2774,7 +3011,7 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 			if err != nil {
 				return err
 			}
-			if opts.Criteria, err = parseTiebreak(str); err != nil {
+			if opts.Criteria, opts.FieldCriteria, err = parseTiebreak(str); err != nil {
 				return err
 			}
 		case "--bind":
@@ -3113,6 +3350,16 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 			if err := setHistory(str); err != nil {
 				return err
 			}
+		case "--no-frecency":
+			opts.Frecency = nil
+		case "--frecency":
+			str, err := nextString("frecency file path required")
+			if err != nil {
+				return err
+			}
+			if opts.Frecency, err = NewFrecency(str); err != nil {
+				return err
+			}
 		case "--history-size":
 			n, err := nextInt("history max size required")
 			if err != nil {
@@ -3420,18 +3667,66 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 		case "--no-listen", "--no-listen-unsafe":
 			opts.ListenAddr = nil
 			opts.Unsafe = false
+		case "--listen-actions":
+			str, err := nextString("actions required")
+			if err != nil {
+				return err
+			}
+			if opts.ListenActions, opts.ListenItems, err = parseListenActions(str); err != nil {
+				return err
+			}
+		case "--no-listen-actions":
+			opts.ListenActions = nil
+			opts.ListenItems = false
+		case "--remote", "--remote-status":
+			clearExitingOpts()
+			request := remoteRequest{}
+			if val != nil {
+				addr, err := parseListenAddress(*val)
+				if err != nil {
+					return err
+				}
+				request.address = &addr
+				val = nil
+			}
+			if arg == "--remote" {
+				if request.actions, err = nextString("action list required"); err != nil {
+					return err
+				}
+				if len(request.actions) == 0 {
+					return errors.New("action list required")
+				}
+			}
+			opts.Remote = &request
 		case "--clear":
 			opts.ClearOnExit = true
 		case "--no-clear":
 			opts.ClearOnExit = false
 		case "--walker":
-			str, err := nextString("walker options required [file][,dir][,follow][,hidden]")
+			str, err := nextString("walker options required [file][,dir][,follow][,hidden][,gitignore]")
 			if err != nil {
 				return err
 			}
 			if opts.WalkerOpts, err = parseWalkerOpts(str); err != nil {
 				return err
 			}
+		case "--source":
+			str, err := nextString("input source required (NAME:COMMAND)")
+			if err != nil {
+				return err
+			}
+			source, err := parseInputSource(str)
+			if err != nil {
+				return err
+			}
+			for _, existing := range opts.Sources {
+				if existing.name == source.name {
+					return errors.New("duplicate input source name: " + source.name)
+				}
+			}
+			opts.Sources = append(opts.Sources, source)
+		case "--no-source":
+			opts.Sources = nil
 		case "--walker-root":
 			if opts.WalkerRoot, err = nextDirs(); err != nil {
 				return err
@@ -3442,6 +3737,53 @@ func parseOptions(index *int, opts *Options, allArgs []string) error {
 				return err
 			}
 			opts.WalkerSkip = filterNonEmpty(strings.Split(str, ","))
+		case "--walker-max-depth":
+			if opts.WalkerFilter.maxDepth, err = nextInt("maximum depth required"); err != nil {
+				return err
+			}
+			if opts.WalkerFilter.maxDepth < 0 {
+				return errors.New("--walker-max-depth must be a non-negative integer")
+			}
+		case "--walker-ext":
+			str, err := nextString("file extensions required")
+			if err != nil {
+				return err
+			}
+			opts.WalkerFilter.exts = parseWalkerExts(str)
+		case "--walker-min-size", "--walker-max-size":
+			str, err := nextString("file size required")
+			if err != nil {
+				return err
+			}
+			size, err := parseWalkerSize(str)
+			if err != nil {
+				return err
+			}
+			if arg == "--walker-min-size" {
+				opts.WalkerFilter.minSize = size
+			} else {
+				opts.WalkerFilter.maxSize = size
+			}
+		case "--walker-watch":
+			opts.WalkerWatch = true
+		case "--no-walker-watch":
+			opts.WalkerWatch = false
+		case "--walker-format":
+			str, err := nextString("walker format required")
+			if err != nil {
+				return err
+			}
+			if opts.WalkerFormat, err = parseWalkerFormat(str); err != nil {
+				return err
+			}
+		case "--walker-newer":
+			str, err := nextString("age required")
+			if err != nil {
+				return err
+			}
+			if opts.WalkerFilter.newer, err = parseWalkerNewer(str, time.Now()); err != nil {
+				return err
+			}
 		case "--threads":
 			if opts.Threads, err = nextInt("number of threads required"); err != nil {
 				return err
@@ -3671,6 +4013,22 @@ func validateOptions(opts *Options) error {
 		return errors.New("--header-border=inline requires --header-lines-border to be inline or unset")
 	}
 
+	if opts.Frecency == nil && slices.Contains(opts.Criteria, byFrecency) {
+		return errors.New("frecency tiebreak requires --frecency")
+	}
+
+	if len(opts.Sources) > util.MaxSources {
+		return fmt.Errorf("at most %d input sources are allowed", util.MaxSources)
+	}
+
+	if len(opts.Sources) == 0 && slices.Contains(opts.Criteria, bySource) {
+		return errors.New("source tiebreak requires --source")
+	}
+
+	if opts.WalkerWatch && !walkerWatchSupported {
+		return errors.New("--walker-watch is only supported on Linux")
+	}
+
 	return nil
 }
 
@@ -3,9 +3,12 @@ package fzf
 import (
 	"fmt"
 	"os"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/junegunn/fzf/src/tui"
+	"github.com/junegunn/fzf/src/util"
 )
 
 func TestDelimiterRegex(t *testing.T) {
@@ -548,6 +551,137 @@ func TestAdditiveExpect(t *testing.T) {
 	}
 }
 
+func TestParseScheme(t *testing.T) {
+	scheme, criteria, err := parseScheme("Custom:boundary=10,camel=8")
+	if err != nil || scheme != "custom:boundary=10,camel=8" || len(criteria) != 2 || criteria[1] != byLength {
+		t.Errorf("Unexpected result: %s, %v, %v", scheme, criteria, err)
+	}
+	for _, str := range []string{"foo", "custom:foo=1", "custom:camel=-1", "custom:boundary"} {
+		if _, _, err := parseScheme(str); err == nil {
+			t.Errorf("Expected error for %q", str)
+		}
+	}
+}
+
+func TestParseTiebreakFrecency(t *testing.T) {
+	criteria, _, err := parseTiebreak("frecency,length")
+	if err != nil || len(criteria) != 3 || criteria[1] != byFrecency || criteria[2] != byLength {
+		t.Errorf("Unexpected result: %v, %v", criteria, err)
+	}
+	if _, _, err := parseTiebreak("frecency,frecency"); err == nil {
+		t.Error("Expected error for duplicate criterion")
+	}
+	if _, err := ParseOptions(true, []string{"--tiebreak=frecency"}); err == nil {
+		t.Error("Expected error without --frecency")
+	}
+}
+
+func TestParseTiebreakField(t *testing.T) {
+	criteria, fields, err := parseTiebreak("length,field:-1:Reverse:numeric,index")
+	if err != nil || len(criteria) != 3 || criteria[1] != byLength || criteria[2] != byField {
+		t.Fatalf("Unexpected result: %v, %v", criteria, err)
+	}
+	if len(fields) != 1 || fields[0].String() != "field:-1:numeric:reverse" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+	for _, str := range []string{
+		"field:", "field:0", "field:x", "field:2:foo", "field:2:numeric:numeric",
+		"field:2,field:2:reverse", "index,field:1", "field:1,field:2", "length,begin,field:1"} {
+		if _, _, err := parseTiebreak(str); err == nil {
+			t.Errorf("Expected error for %q", str)
+		}
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	opts, err := ParseOptions(true, []string{"--source=branch:git branch", "--source", "tag:echo a:b", "--tiebreak=source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []inputSource{{"branch", "git branch"}, {"tag", "echo a:b"}}
+	if !slices.Equal(opts.Sources, expected) {
+		t.Errorf("Unexpected sources: %v", opts.Sources)
+	}
+	if len(opts.Criteria) != 2 || opts.Criteria[1] != bySource {
+		t.Errorf("Unexpected criteria: %v", opts.Criteria)
+	}
+	if opts, _ := ParseOptions(true, []string{"--source=a:ls", "--no-source"}); len(opts.Sources) > 0 {
+		t.Errorf("Sources should be cleared: %v", opts.Sources)
+	}
+
+	for _, args := range [][]string{
+		{"--source=ls"}, {"--source=:ls"}, {"--source=a b:ls"}, {"--source=a:"}, {"--source=a: "},
+		{"--source=a:ls", "--source=a:pwd"}, {"--tiebreak=source"}, {"--tiebreak=source,source"}} {
+		if _, err := ParseOptions(true, args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+	args := []string{}
+	for idx := range util.MaxSources + 1 {
+		args = append(args, fmt.Sprintf("--source=s%d:ls", idx))
+	}
+	if _, err := ParseOptions(true, args); err == nil {
+		t.Error("Expected error for too many sources")
+	}
+}
+
+func TestParseWalkerFilter(t *testing.T) {
+	index := 0
+	opts := defaultOptions()
+	words := []string{"--walker-max-depth=2", "--walker-ext", ".Go,md,", "--walker-min-size=1K",
+		"--walker-max-size=2MiB", "--walker-newer=2d", "--walker=dir"}
+	if err := parseOptions(&index, opts, words); err != nil {
+		t.Fatal(err)
+	}
+	filter := opts.WalkerFilter
+	if filter.maxDepth != 2 || fmt.Sprint(filter.exts) != "[go md]" ||
+		filter.minSize != 1024 || filter.maxSize != 2*1024*1024 || filter.newer.IsZero() {
+		t.Errorf("Unexpected filter: %+v", filter)
+	}
+	if !opts.WalkerOpts.dir || opts.WalkerOpts.file {
+		t.Errorf("Unexpected walker options: %+v", opts.WalkerOpts)
+	}
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	for str, expected := range map[string]time.Time{
+		"30m":        now.Add(-30 * time.Minute),
+		"2d":         now.Add(-48 * time.Hour),
+		"1w":         now.Add(-7 * 24 * time.Hour),
+		"2024-01-01": time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+	} {
+		if newer, err := parseWalkerNewer(str, now); err != nil || !newer.Equal(expected) {
+			t.Errorf("%s: expected %v, got %v (%v)", str, expected, newer, err)
+		}
+	}
+	for _, str := range []string{"", "d", "2x", "-1d", "yesterday"} {
+		if _, err := parseWalkerNewer(str, now); err == nil {
+			t.Errorf("Expected error for %q", str)
+		}
+	}
+	for _, words := range [][]string{{"--walker-max-depth=-1"}, {"--walker-min-size=-1"}, {"--walker-max-size=1X"}} {
+		index := 0
+		if err := parseOptions(&index, defaultOptions(), words); err == nil {
+			t.Errorf("Expected error for %v", words)
+		}
+	}
+}
+
+func TestParseWalkerFormat(t *testing.T) {
+	format, err := parseWalkerFormat(`{size}\t{mtime} {path}{`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := walkerFormat{{field: "size"}, {text: "\t"}, {field: "mtime"}, {text: " "}, {field: "path"}, {text: "{"}}
+	if !slices.Equal(format, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, format)
+	}
+	for _, str := range []string{"{foo}", "{path}{}", "{ size }"} {
+		if _, err := parseWalkerFormat(str); err == nil {
+			t.Errorf("Expected error for %q", str)
+		}
+	}
+}
+
 func TestValidateSign(t *testing.T) {
 	testCases := []struct {
 		inputSign string
@@ -17,6 +17,12 @@ import (
 // !'inverse-fuzzy
 // !^inverse-prefix-exact
 // !inverse-suffix-exact$
+// %approximate
+// /regex/
+// !/inverse-regex/
+// {2}field-scoped
+// {3}>10M {2}<=2024-01-01 (comparison)
+// (grouped | terms) !(negated group)
 
 type termType int
 
@@ -27,14 +33,29 @@ const (
 	termPrefix
 	termSuffix
 	termEqual
+	termApprox
+	termRegex
+	termCompare
+	termGroup
 )
 
+// regexCacheKeyPrefix marks a regular expression in the cache key. Unlike
+// the other terms, the result of a substring of an expression is not
+// a superset of the result of the expression, so ChunkCache.Search should
+// skip the keys containing it. NUL is not expected in the query.
+const regexCacheKeyPrefix = "\x00"
+
 type term struct {
 	typ           termType
 	inv           bool
 	text          []rune
 	caseSensitive bool
 	normalize     bool
+	regex         algo.Algo   // Matcher of termRegex
+	nth           []Range     // Fields to match instead of Pattern.nth
+	source        bool        // Match the name of the input source instead
+	compare       *comparison // Condition of termCompare
+	group         []termSet   // Term sets of termGroup
 }
 
 // String returns the string representation of a term.
@@ -58,10 +79,11 @@ type Pattern struct {
 	sortable      bool
 	cacheable     bool
 	cacheKey      string
+	termKeys      []string // Cache keys of the term sets for per-term cache
 	delimiter     Delimiter
 	nth           []Range
 	revision      revision
-	procFun       [6]algo.Algo
+	procFun       [7]algo.Algo
 	cache         *ChunkCache
 	denylist      map[int32]struct{}
 	startIndex    int32
@@ -99,6 +121,8 @@ func BuildPattern(cache *ChunkCache, patternCache map[string]*Pattern, fuzzy boo
 	caseSensitive := true
 	sortable := true
 	termSets := []termSet{}
+	// False in filter mode where the cache is not reused
+	useCache := cacheable
 
 	if extended {
 		termSets = parseTerms(fuzzy, caseMode, normalize, asString)
@@ -107,12 +131,12 @@ func BuildPattern(cache *ChunkCache, patternCache map[string]*Pattern, fuzzy boo
 	Loop:
 		for _, termSet := range termSets {
 			for idx, term := range termSet {
-				if !term.inv {
+				if !term.inv && term.typ != termCompare && !term.source {
 					sortable = true
 				}
 				// If the query contains inverse search terms or OR operators,
 				// we cannot cache the search scope
-				if !cacheable || idx > 0 || term.inv || fuzzy && term.typ != termFuzzy || !fuzzy && term.typ != termExact {
+				if !cacheable || idx > 0 || term.inv || len(term.nth) > 0 || term.source || term.typ != termRegex && (fuzzy && term.typ != termFuzzy || !fuzzy && term.typ != termExact) {
 					cacheable = false
 					if sortable {
 						// Can't break until we see at least one non-inverse term
@@ -121,6 +145,11 @@ func BuildPattern(cache *ChunkCache, patternCache map[string]*Pattern, fuzzy boo
 				}
 			}
 		}
+		// Empty query is sorted when the frecency tiebreak is used, so that
+		// the frequently selected items come first
+		if len(termSets) == 0 && sortByFrecency() {
+			sortable = true
+		}
 	} else {
 		lowerString := strings.ToLower(asString)
 		normalize = normalize &&
@@ -153,6 +182,9 @@ func BuildPattern(cache *ChunkCache, patternCache map[string]*Pattern, fuzzy boo
 	}
 
 	ptr.cacheKey = ptr.buildCacheKey()
+	if useCache {
+		ptr.termKeys = ptr.buildTermKeys()
+	}
 	ptr.directAlgo, ptr.directTerm = ptr.buildDirectAlgo(fuzzyAlgo)
 	ptr.procFun[termFuzzy] = fuzzyAlgo
 	ptr.procFun[termEqual] = algo.EqualMatch
@@ -160,6 +192,7 @@ func BuildPattern(cache *ChunkCache, patternCache map[string]*Pattern, fuzzy boo
 	ptr.procFun[termExactBoundary] = algo.ExactMatchBoundary
 	ptr.procFun[termPrefix] = algo.PrefixMatch
 	ptr.procFun[termSuffix] = algo.SuffixMatch
+	ptr.procFun[termApprox] = algo.ApproximateMatch
 
 	patternCache[asString] = ptr
 	return ptr
@@ -168,84 +201,292 @@ func BuildPattern(cache *ChunkCache, patternCache map[string]*Pattern, fuzzy boo
 func parseTerms(fuzzy bool, caseMode Case, normalize bool, str string) []termSet {
 	str = strings.ReplaceAll(str, "\\ ", "\t")
 	tokens := _splitRegex.Split(str, -1)
+	sets, _ := parseTermSets(fuzzy, caseMode, normalize, splitGroups(tokens))
+	return sets
+}
+
+// queryElem is either a token of the query, or the beginning or the end of
+// a parenthesized group
+type queryElem struct {
+	token string
+	open  bool
+	close bool
+	inv   bool
+}
+
+// splitGroups splits the parentheses at the beginning and at the end of the
+// tokens. The tokens are returned as they are if the parentheses are not
+// balanced, so that the queries without groups are parsed as before.
+func splitGroups(tokens []string) []queryElem {
+	plain := make([]queryElem, len(tokens))
+	for idx, token := range tokens {
+		plain[idx] = queryElem{token: token}
+	}
+
+	elems := []queryElem{}
+	depth := 0
+	grouped := false
+	for _, token := range tokens {
+		for {
+			if strings.HasPrefix(token, "(") {
+				elems = append(elems, queryElem{open: true})
+				token = token[1:]
+			} else if strings.HasPrefix(token, "!(") {
+				elems = append(elems, queryElem{open: true, inv: true})
+				token = token[2:]
+			} else {
+				break
+			}
+			depth++
+			grouped = true
+		}
+		closes := len(token) - len(strings.TrimRight(token, ")"))
+		token = token[:len(token)-closes]
+		if len(token) > 0 {
+			elems = append(elems, queryElem{token: token})
+		}
+		for range closes {
+			if depth--; depth < 0 {
+				return plain
+			}
+			elems = append(elems, queryElem{close: true})
+		}
+	}
+	if !grouped || depth != 0 {
+		return plain
+	}
+	return elems
+}
+
+// parseTermSets parses the elements up to the end of the current group.
+// A group is a term whose termSets are evaluated recursively. Returns the
+// term sets and the number of the elements consumed.
+func parseTermSets(fuzzy bool, caseMode Case, normalize bool, elems []queryElem) ([]termSet, int) {
 	sets := []termSet{}
 	set := termSet{}
 	switchSet := false
 	afterBar := false
-	for _, token := range tokens {
-		typ, inv, text := termFuzzy, false, strings.ReplaceAll(token, "\t", " ")
-		lowerText := strings.ToLower(text)
-		caseSensitive := caseMode == CaseRespect ||
-			caseMode == CaseSmart && text != lowerText
-		normalizeTerm := normalize &&
-			lowerText == string(algo.NormalizeRunes([]rune(lowerText)))
-		if !caseSensitive {
-			text = lowerText
+	add := func(t term) {
+		if switchSet {
+			sets = append(sets, set)
+			set = termSet{}
 		}
-		if !fuzzy {
-			typ = termExact
+		set = append(set, t)
+		switchSet = true
+	}
+	idx := 0
+	for ; idx < len(elems); idx++ {
+		elem := elems[idx]
+		if elem.close {
+			idx++
+			break
+		}
+		if elem.open {
+			afterBar = false
+			group, consumed := parseTermSets(fuzzy, caseMode, normalize, elems[idx+1:])
+			idx += consumed
+			if len(group) > 0 {
+				add(term{typ: termGroup, inv: elem.inv, group: group})
+			}
+			continue
 		}
 
-		if len(set) > 0 && !afterBar && text == "|" {
+		if len(set) > 0 && !afterBar && elem.token == "|" {
 			switchSet = false
 			afterBar = true
 			continue
 		}
 		afterBar = false
 
-		if strings.HasPrefix(text, "!") {
-			inv = true
-			typ = termExact
-			text = text[1:]
+		if t, ok := parseTerm(fuzzy, caseMode, normalize, elem.token); ok {
+			add(t)
 		}
+	}
+	if len(set) > 0 {
+		sets = append(sets, set)
+	}
+	return sets, idx
+}
 
-		if text != "$" && strings.HasSuffix(text, "$") {
-			typ = termSuffix
-			text = text[:len(text)-1]
+// parseTerm parses a single token of the query. Returns false if the token
+// is effectively empty.
+func parseTerm(fuzzy bool, caseMode Case, normalize bool, token string) (term, bool) {
+	typ, inv, text := termFuzzy, false, strings.ReplaceAll(token, "\t", " ")
+	text, nth := parseFieldScope(text)
+	source := false
+	if len(nth) > 0 {
+		if compareTerm, ok := parseCompareTerm(text); ok {
+			compareTerm.nth = nth
+			return compareTerm, true
 		}
+	} else if len(sourceNames) > 0 {
+		text, source = parseSourceScope(text)
+	}
+	if regexTerm, ok := parseRegexTerm(caseMode, text); ok {
+		regexTerm.nth = nth
+		regexTerm.source = source
+		return regexTerm, true
+	}
+	lowerText := strings.ToLower(text)
+	caseSensitive := caseMode == CaseRespect ||
+		caseMode == CaseSmart && text != lowerText
+	normalizeTerm := normalize &&
+		lowerText == string(algo.NormalizeRunes([]rune(lowerText)))
+	if !caseSensitive {
+		text = lowerText
+	}
+	if !fuzzy {
+		typ = termExact
+	}
 
-		if len(text) > 2 && strings.HasPrefix(text, "'") && strings.HasSuffix(text, "'") {
-			typ = termExactBoundary
-			text = text[1 : len(text)-1]
-		} else if strings.HasPrefix(text, "'") {
-			// Flip exactness
-			if fuzzy && !inv {
-				typ = termExact
-			} else {
-				typ = termFuzzy
-			}
-			text = text[1:]
-		} else if strings.HasPrefix(text, "^") {
-			if typ == termSuffix {
-				typ = termEqual
-			} else {
-				typ = termPrefix
-			}
-			text = text[1:]
-		}
+	if strings.HasPrefix(text, "!") {
+		inv = true
+		typ = termExact
+		text = text[1:]
+	}
 
-		if len(text) > 0 {
-			if switchSet {
-				sets = append(sets, set)
-				set = termSet{}
-			}
-			textRunes := []rune(text)
-			if normalizeTerm {
-				textRunes = algo.NormalizeRunes(textRunes)
-			}
-			set = append(set, term{
-				typ:           typ,
-				inv:           inv,
-				text:          textRunes,
-				caseSensitive: caseSensitive,
-				normalize:     normalizeTerm})
-			switchSet = true
+	approx := len(text) > 1 && strings.HasPrefix(text, "%")
+	if approx {
+		text = text[1:]
+	} else if text != "$" && strings.HasSuffix(text, "$") {
+		typ = termSuffix
+		text = text[:len(text)-1]
+	}
+
+	if approx {
+		typ = termApprox
+	} else if len(text) > 2 && strings.HasPrefix(text, "'") && strings.HasSuffix(text, "'") {
+		typ = termExactBoundary
+		text = text[1 : len(text)-1]
+	} else if strings.HasPrefix(text, "'") {
+		// Flip exactness
+		if fuzzy && !inv {
+			typ = termExact
+		} else {
+			typ = termFuzzy
+		}
+		text = text[1:]
+	} else if strings.HasPrefix(text, "^") {
+		if typ == termSuffix {
+			typ = termEqual
+		} else {
+			typ = termPrefix
 		}
+		text = text[1:]
 	}
-	if len(set) > 0 {
-		sets = append(sets, set)
+
+	if len(text) == 0 {
+		return term{}, false
 	}
-	return sets
+	textRunes := []rune(text)
+	if normalizeTerm {
+		textRunes = algo.NormalizeRunes(textRunes)
+	}
+	return term{
+		typ:           typ,
+		inv:           inv,
+		text:          textRunes,
+		caseSensitive: caseSensitive,
+		normalize:     normalizeTerm,
+		nth:           nth,
+		source:        source}, true
+}
+
+// parseFieldScope strips the field index expression of a field-scoped term
+// (e.g. {2}foo, !{-1}bar, {2..3}baz) and returns the rest of the term and the
+// ranges of the fields to match. The expression is enclosed in braces as in
+// the placeholders, so that common text such as 10:30 is not mistaken for it.
+func parseFieldScope(text string) (string, []Range) {
+	inv := strings.HasPrefix(text, "!")
+	body := text
+	if inv {
+		body = text[1:]
+	}
+	if !strings.HasPrefix(body, "{") {
+		return text, nil
+	}
+	idx := strings.IndexByte(body, '}')
+	if idx <= 1 || idx == len(body)-1 {
+		return text, nil
+	}
+	nth, err := splitNth(body[1:idx])
+	if err != nil {
+		return text, nil
+	}
+	if inv {
+		return "!" + body[idx+1:], nth
+	}
+	return body[idx+1:], nth
+}
+
+// parseSourceScope strips the prefix of a term that matches the name of the
+// input source (e.g. source:git, !source:tag)
+func parseSourceScope(text string) (string, bool) {
+	inv := strings.HasPrefix(text, "!")
+	body := text
+	if inv {
+		body = text[1:]
+	}
+	rest, found := strings.CutPrefix(body, "source:")
+	if !found || len(rest) == 0 {
+		return text, false
+	}
+	if inv {
+		return "!" + rest, true
+	}
+	return rest, true
+}
+
+var _regexEscapeRegex = regexp.MustCompile(`\\.`)
+
+// parseRegexTerm parses /regex/ or !/regex/ term. An invalid expression is
+// not a regex term, so it's matched as a normal term while being typed.
+func parseRegexTerm(caseMode Case, text string) (term, bool) {
+	inv := strings.HasPrefix(text, "!")
+	if inv {
+		text = text[1:]
+	}
+	if len(text) <= 2 || !strings.HasPrefix(text, "/") || !strings.HasSuffix(text, "/") {
+		return term{}, false
+	}
+	text = text[1 : len(text)-1]
+
+	// Escape sequences such as \D or \S don't count for smart-case
+	unescaped := _regexEscapeRegex.ReplaceAllString(text, "")
+	caseSensitive := caseMode == CaseRespect ||
+		caseMode == CaseSmart && unescaped != strings.ToLower(unescaped)
+	expr := text
+	if !caseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return term{}, false
+	}
+	return term{
+		typ:           termRegex,
+		inv:           inv,
+		text:          []rune(text),
+		caseSensitive: caseSensitive,
+		regex:         algo.RegexMatch(re)}, true
+}
+
+// parseCompareTerm parses the comparison of a field-scoped term
+// (e.g. >10M, !<=2024-01-01)
+func parseCompareTerm(text string) (term, bool) {
+	inv := strings.HasPrefix(text, "!")
+	if inv {
+		text = text[1:]
+	}
+	compare, ok := parseComparison(text)
+	if !ok {
+		return term{}, false
+	}
+	return term{
+		typ:     termCompare,
+		inv:     inv,
+		text:    []rune(text),
+		compare: compare}, true
 }
 
 // IsEmpty returns true if the pattern is effectively empty
@@ -270,13 +511,53 @@ func (p *Pattern) buildCacheKey() string {
 	}
 	cacheableTerms := []string{}
 	for _, termSet := range p.termSets {
-		if len(termSet) == 1 && !termSet[0].inv && (p.fuzzy || termSet[0].typ == termExact) {
+		if len(termSet[0].nth) > 0 || termSet[0].source {
+			continue
+		}
+		if termSet[0].typ == termGroup {
+			continue
+		}
+		if len(termSet) == 1 && !termSet[0].inv && termSet[0].typ == termRegex {
+			cacheableTerms = append(cacheableTerms, regexCacheKeyPrefix+string(termSet[0].text))
+		} else if len(termSet) == 1 && !termSet[0].inv && termSet[0].typ != termApprox && (p.fuzzy || termSet[0].typ == termExact) {
 			cacheableTerms = append(cacheableTerms, string(termSet[0].text))
 		}
 	}
 	return strings.Join(cacheableTerms, "\t")
 }
 
+// buildTermKeys returns the cache keys of the term sets whose results can be
+// cached individually. The key is empty for the other term sets.
+func (p *Pattern) buildTermKeys() []string {
+	if !p.extended {
+		return nil
+	}
+	keys := make([]string, len(p.termSets))
+	found := false
+	for idx, termSet := range p.termSets {
+		// The matched term sets are recorded in a 64-bit mask
+		if idx >= 64 {
+			break
+		}
+		term := termSet[0]
+		if len(termSet) > 1 || term.inv || len(term.nth) > 0 || term.source {
+			continue
+		}
+		// Only the terms of the default type so that the same key always
+		// means the same term
+		if term.typ == termRegex {
+			keys[idx] = regexCacheKeyPrefix + string(term.text)
+		} else if p.fuzzy && term.typ == termFuzzy || !p.fuzzy && term.typ == termExact {
+			keys[idx] = string(term.text)
+		}
+		found = found || len(keys[idx]) > 0
+	}
+	if !found {
+		return nil
+	}
+	return keys
+}
+
 // buildDirectAlgo returns the algo function and term for the direct fast path
 // in matchChunk. Returns (nil, nil) if the pattern is not suitable.
 // Requirements: extended mode, single term set with single non-inverse fuzzy term, no nth.
@@ -286,7 +567,7 @@ func (p *Pattern) buildDirectAlgo(fuzzyAlgo algo.Algo) (algo.Algo, *term) {
 	}
 	if len(p.termSets) == 1 && len(p.termSets[0]) == 1 {
 		t := &p.termSets[0][0]
-		if !t.inv && t.typ == termFuzzy {
+		if !t.inv && t.typ == termFuzzy && len(t.nth) == 0 && !t.source {
 			return fuzzyAlgo, t
 		}
 	}
@@ -309,17 +590,41 @@ func (p *Pattern) Match(chunk *Chunk, slab *util.Slab) []Result {
 	}
 	if cachedBitmap == nil {
 		cachedBitmap = p.cache.Search(chunk, cacheKey)
+
+		// Per-term cache: intersection of the results of the terms
+		var termBitmap *ChunkBitmap
+		if len(p.termKeys) > 0 {
+			termBitmap = p.cache.SearchTerms(chunk, p.termKeys)
+		}
+		if termBitmap != nil {
+			if cachedBitmap != nil {
+				for idx := range termBitmap {
+					termBitmap[idx] &= cachedBitmap[idx]
+				}
+			}
+			cachedBitmap = termBitmap
+		}
 	}
 
-	matches, bitmap := p.matchChunk(chunk, cachedBitmap, slab)
+	// The results of a single term query are already cached by the query
+	var terms []TermBitmap
+	if len(p.termSets) > 1 && len(p.termKeys) > 0 && chunk.IsFull() {
+		terms = make([]TermBitmap, len(p.termKeys))
+	}
+	matches, bitmap := p.matchChunk(chunk, cachedBitmap, terms, slab)
 
 	if p.cacheable {
 		p.cache.Add(chunk, cacheKey, bitmap, len(matches))
 	}
+	if terms != nil {
+		p.cache.AddTerms(chunk, p.termKeys, terms)
+	}
 	return matches
 }
 
-func (p *Pattern) matchChunk(chunk *Chunk, cachedBitmap *ChunkBitmap, slab *util.Slab) ([]Result, ChunkBitmap) {
+// matchChunk returns the matches in the chunk and their bitmap. If terms is
+// given, the results of the term sets are recorded for the per-term cache.
+func (p *Pattern) matchChunk(chunk *Chunk, cachedBitmap *ChunkBitmap, terms []TermBitmap, slab *util.Slab) ([]Result, ChunkBitmap) {
 	matches := []Result{}
 	var bitmap ChunkBitmap
 
@@ -360,7 +665,7 @@ func (p *Pattern) matchChunk(chunk *Chunk, cachedBitmap *ChunkBitmap, slab *util
 			if hasCachedBitmap && cachedBitmap[idx/64]&(uint64(1)<<(idx%64)) == 0 {
 				continue
 			}
-			if match, _, _ := p.MatchItem(&chunk.items[idx], p.withPos, slab); match.item != nil {
+			if match := p.matchItem(&chunk.items[idx], idx, terms, slab); match.item != nil {
 				bitmap[idx/64] |= uint64(1) << (idx % 64)
 				matches = append(matches, match)
 			}
@@ -375,7 +680,7 @@ func (p *Pattern) matchChunk(chunk *Chunk, cachedBitmap *ChunkBitmap, slab *util
 		if _, prs := p.denylist[chunk.items[idx].Index()]; prs {
 			continue
 		}
-		if match, _, _ := p.MatchItem(&chunk.items[idx], p.withPos, slab); match.item != nil {
+		if match := p.matchItem(&chunk.items[idx], idx, terms, slab); match.item != nil {
 			bitmap[idx/64] |= uint64(1) << (idx % 64)
 			matches = append(matches, match)
 		}
@@ -383,21 +688,54 @@ func (p *Pattern) matchChunk(chunk *Chunk, cachedBitmap *ChunkBitmap, slab *util
 	return matches, bitmap
 }
 
+// matchItem is MatchItem for matchChunk. If terms is given, the results of
+// the term sets for the item at the index of the chunk are recorded.
+func (p *Pattern) matchItem(item *Item, idx int, terms []TermBitmap, slab *util.Slab) Result {
+	if terms == nil {
+		match, _, _ := p.MatchItem(item, p.withPos, slab)
+		return match
+	}
+	var matchedSets uint64
+	offsets, score, _ := p.extendedMatch(item, p.withPos, slab, &matchedSets)
+	bit := uint64(1) << (idx % 64)
+	for i, key := range p.termKeys {
+		if len(key) > 0 {
+			terms[i].scope[idx/64] |= bit
+			if matchedSets&(uint64(1)<<i) > 0 {
+				terms[i].matched[idx/64] |= bit
+			}
+		}
+	}
+	if len(offsets) == len(p.termSets) {
+		return buildResult(item, offsets, score)
+	}
+	return Result{}
+}
+
 // MatchItem returns the match result if the Item is a match.
 // A zero-value Result (with item == nil) indicates no match.
 func (p *Pattern) MatchItem(item *Item, withPos bool, slab *util.Slab) (Result, []Offset, *[]int) {
+	if offsets, score, pos, matched := p.MatchScore(item, withPos, slab); matched {
+		return buildResult(item, offsets, score), offsets, pos
+	}
+	return Result{}, nil, nil
+}
+
+// MatchScore returns the offsets, the score, and the positions of the Item
+// if it is a match. Unlike MatchItem, it doesn't build a Result, so the score
+// is not clamped to the range of the sort key.
+func (p *Pattern) MatchScore(item *Item, withPos bool, slab *util.Slab) ([]Offset, int, *[]int, bool) {
 	if p.extended {
-		if offsets, bonus, pos := p.extendedMatch(item, withPos, slab); len(offsets) == len(p.termSets) {
-			return buildResult(item, offsets, bonus), offsets, pos
+		if offsets, bonus, pos := p.extendedMatch(item, withPos, slab, nil); len(offsets) == len(p.termSets) {
+			return offsets, bonus, pos, true
 		}
-		return Result{}, nil, nil
+		return nil, 0, nil, false
 	}
 	offset, bonus, pos := p.basicMatch(item, withPos, slab)
 	if sidx := offset[0]; sidx >= 0 {
-		offsets := []Offset{offset}
-		return buildResult(item, offsets, bonus), offsets, pos
+		return []Offset{offset}, bonus, pos, true
 	}
-	return Result{}, nil, nil
+	return nil, 0, nil, false
 }
 
 func (p *Pattern) basicMatch(item *Item, withPos bool, slab *util.Slab) (Offset, int, *[]int) {
@@ -413,26 +751,47 @@ func (p *Pattern) basicMatch(item *Item, withPos bool, slab *util.Slab) (Offset,
 	return p.iter(algo.ExactMatchNaive, input, p.caseSensitive, p.normalize, p.forward, p.text, withPos, slab)
 }
 
-func (p *Pattern) extendedMatch(item *Item, withPos bool, slab *util.Slab) ([]Offset, int, *[]int) {
+func (p *Pattern) extendedMatch(item *Item, withPos bool, slab *util.Slab, matchedSets *uint64) ([]Offset, int, *[]int) {
 	var input []Token
 	if len(p.nth) == 0 {
 		input = []Token{{text: &item.text, prefixLength: 0}}
 	} else {
 		input = p.transformInput(item)
 	}
+	// Tokens of the whole item for field-scoped terms. Lazily initialized.
+	var allTokens []Token
+	return p.matchTermSets(p.termSets, item, input, &allTokens, withPos, slab, matchedSets)
+}
+
+// matchTermSets evaluates the term sets against the input. The caller should
+// check if the number of the returned offsets equals to the number of the
+// term sets to see if the item is a match. If matchedSets is given, the bits
+// of the matched term sets are set.
+func (p *Pattern) matchTermSets(termSets []termSet, item *Item, input []Token, allTokens *[]Token, withPos bool, slab *util.Slab, matchedSets *uint64) ([]Offset, int, *[]int) {
 	offsets := []Offset{}
 	var totalScore int
 	var allPos *[]int
 	if withPos {
 		allPos = &[]int{}
 	}
-	for _, termSet := range p.termSets {
+	for setIdx, termSet := range termSets {
 		var offset Offset
 		var currentScore int
 		matched := false
 		for _, term := range termSet {
-			pfun := p.procFun[term.typ]
-			off, score, pos := p.iter(pfun, input, term.caseSensitive, term.normalize, p.forward, term.text, withPos, slab)
+			var off Offset
+			var score int
+			var pos *[]int
+			if term.typ == termGroup {
+				var groupOffsets []Offset
+				groupOffsets, score, pos = p.matchTermSets(term.group, item, input, allTokens, withPos, slab, nil)
+				off = Offset{-1, -1}
+				if len(groupOffsets) == len(term.group) {
+					off = spanOffsets(groupOffsets)
+				}
+			} else {
+				off, score, pos = p.matchTerm(term, item, input, allTokens, withPos, slab)
+			}
 			if sidx := off[0]; sidx >= 0 {
 				if term.inv {
 					continue
@@ -458,11 +817,75 @@ func (p *Pattern) extendedMatch(item *Item, withPos bool, slab *util.Slab) ([]Of
 		if matched {
 			offsets = append(offsets, offset)
 			totalScore += currentScore
+			if matchedSets != nil && setIdx < 64 {
+				*matchedSets |= uint64(1) << setIdx
+			}
 		}
 	}
 	return offsets, totalScore, allPos
 }
 
+// matchTerm matches a term other than termGroup against the input
+func (p *Pattern) matchTerm(term term, item *Item, input []Token, allTokens *[]Token, withPos bool, slab *util.Slab) (Offset, int, *[]int) {
+	if len(term.nth) > 0 && *allTokens == nil {
+		*allTokens = Tokenize(item.text.ToString(), p.delimiter)
+	}
+	if term.typ == termCompare {
+		// Comparison is a filter that doesn't contribute to the score
+		// nor to the highlighted positions
+		for _, token := range Transform(*allTokens, term.nth) {
+			if term.compare.Match(StripLastDelimiter(token.text.ToString(), p.delimiter)) {
+				if withPos {
+					return Offset{0, 0}, 0, &[]int{}
+				}
+				return Offset{0, 0}, 0, nil
+			}
+		}
+		return Offset{-1, -1}, 0, nil
+	}
+
+	termInput := input
+	if len(term.nth) > 0 {
+		termInput = p.transform(*allTokens, term.nth)
+	}
+	var pfun algo.Algo
+	if term.typ == termRegex {
+		pfun = term.regex
+	} else {
+		pfun = p.procFun[term.typ]
+	}
+	if term.source {
+		// Like comparison, the name of the input source is a filter
+		if res, _ := pfun(term.caseSensitive, term.normalize, p.forward, item.sourceNameChars(), term.text, false, slab); res.Start >= 0 {
+			if withPos {
+				return Offset{0, 0}, 0, &[]int{}
+			}
+			return Offset{0, 0}, 0, nil
+		}
+		return Offset{-1, -1}, 0, nil
+	}
+	return p.iter(pfun, termInput, term.caseSensitive, term.normalize, p.forward, term.text, withPos, slab)
+}
+
+// spanOffsets returns the smallest range that covers the non-empty offsets
+func spanOffsets(offsets []Offset) Offset {
+	span := Offset{0, 0}
+	found := false
+	for _, off := range offsets {
+		if off[0] >= off[1] {
+			continue
+		}
+		if !found || off[0] < span[0] {
+			span[0] = off[0]
+		}
+		if !found || off[1] > span[1] {
+			span[1] = off[1]
+		}
+		found = true
+	}
+	return span
+}
+
 func (p *Pattern) transformInput(item *Item) []Token {
 	if item.transformed != nil {
 		transformed := *item.transformed
@@ -472,7 +895,13 @@ func (p *Pattern) transformInput(item *Item) []Token {
 	}
 
 	tokens := Tokenize(item.text.ToString(), p.delimiter)
-	ret := Transform(tokens, p.nth)
+	ret := p.transform(tokens, p.nth)
+	item.transformed = &transformed{p.revision, ret}
+	return ret
+}
+
+func (p *Pattern) transform(tokens []Token, nth []Range) []Token {
+	ret := Transform(tokens, nth)
 	// Strip the last delimiter to allow suffix match
 	if len(ret) > 0 && !p.delimiter.IsAwk() {
 		chars := ret[len(ret)-1].text
@@ -480,7 +909,6 @@ func (p *Pattern) transformInput(item *Item) []Token {
 		newChars := util.ToChars(stringBytes(stripped))
 		ret[len(ret)-1].text = &newChars
 	}
-	item.transformed = &transformed{p.revision, ret}
 	return ret
 }
 
@@ -1,6 +1,7 @@
 package fzf
 
 import (
+	"math/bits"
 	"reflect"
 	"runtime"
 	"testing"
@@ -42,6 +43,267 @@ func TestParseTermsExtended(t *testing.T) {
 	}
 }
 
+func TestParseTermsApprox(t *testing.T) {
+	terms := parseTerms(true, CaseSmart, false, "%recieve !%teh %foo$ % %%")
+	if len(terms) != 5 ||
+		terms[0][0].typ != termApprox || terms[0][0].inv || string(terms[0][0].text) != "recieve" ||
+		terms[1][0].typ != termApprox || !terms[1][0].inv || string(terms[1][0].text) != "teh" ||
+		terms[2][0].typ != termApprox || string(terms[2][0].text) != "foo$" ||
+		terms[3][0].typ != termFuzzy || string(terms[3][0].text) != "%" ||
+		terms[4][0].typ != termApprox || string(terms[4][0].text) != "%" {
+		t.Errorf("%v", terms)
+	}
+}
+
+func TestParseFieldScope(t *testing.T) {
+	for text, expected := range map[string]string{
+		"{2}foo":     "foo",
+		"!{-1}^foo":  "!^foo",
+		"{2..3}/x/":  "/x/",
+		"{1,3}foo":   "foo",
+		"{2}":        "{2}",
+		"{}foo":      "{}foo",
+		"{foo}bar":   "{foo}bar",
+		"{2foo":      "{2foo",
+		"'{2}foo":    "'{2}foo",
+		"{1}{2}3":    "{2}3",
+		"!{0}foo":    "!{0}foo",
+		"{--1}foo":   "{--1}foo",
+		"{1..2..}ab": "{1..2..}ab",
+		"10:30":      "10:30",
+		"42:func":    "42:func",
+		"2:foo":      "2:foo",
+	} {
+		rest, nth := parseFieldScope(text)
+		if rest != expected || (rest == text) != (nth == nil) {
+			t.Errorf("Unexpected result for %q: %q, %v", text, rest, nth)
+		}
+	}
+}
+
+func TestFieldScopedTerm(t *testing.T) {
+	pattern := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("{1}bob !{-1}alice {2..}fix$"))
+	if pattern.cacheable || len(pattern.CacheKey()) > 0 || pattern.directAlgo != nil {
+		t.Errorf("Field-scoped terms should not be cached: %q", pattern.CacheKey())
+	}
+	for input, expected := range map[string]bool{
+		"bob alice fix":  true,
+		"alice bob fix":  false,
+		"bob fix alice":  false,
+		"bob fix bugfix": true,
+	} {
+		item := Item{text: util.ToChars([]byte(input))}
+		if _, _, _, matched := pattern.MatchScore(&item, false, slab); matched != expected {
+			t.Errorf("Expected %v for %q", expected, input)
+		}
+	}
+
+	// Offsets and positions are relative to the whole item
+	pattern = buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("{2}ab"))
+	item := Item{text: util.ToChars([]byte("ab xab"))}
+	if offsets, _, pos, _ := pattern.MatchScore(&item, true, slab); offsets[0] != (Offset{4, 6}) || len(*pos) != 2 || (*pos)[0] != 5 {
+		t.Errorf("Unexpected match: %v / %v", offsets, *pos)
+	}
+}
+
+func TestCompareTerm(t *testing.T) {
+	terms := parseTerms(true, CaseSmart, false, ">100 {1}>100 !{2}<=1K {1}>foo {1}'>100")
+	if len(terms) != 5 ||
+		terms[0][0].typ != termFuzzy ||
+		terms[1][0].typ != termCompare || terms[1][0].inv ||
+		terms[2][0].typ != termCompare || !terms[2][0].inv ||
+		terms[3][0].typ != termFuzzy || string(terms[3][0].text) != ">foo" ||
+		terms[4][0].typ != termExact {
+		t.Errorf("%v", terms)
+	}
+
+	pattern := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("{2}>10M {3}<2024-01-01 log"))
+	for input, expected := range map[string]bool{
+		"a.log 20M 2023-12-01":  true,
+		"a.log 20M 2024-12-01":  false,
+		"a.log 2M 2023-12-01":   false,
+		"a.log big 2023-12-01":  false,
+		"a.txt 20M 2023-12-01":  false,
+		"a.log  1G  2023-01-01": true,
+	} {
+		item := Item{text: util.ToChars([]byte(input))}
+		if _, _, _, matched := pattern.MatchScore(&item, false, slab); matched != expected {
+			t.Errorf("Expected %v for %q", expected, input)
+		}
+	}
+
+	// Comparisons don't contribute to the score nor to the positions
+	item := Item{text: util.ToChars([]byte("a.log 20M 2023-12-01"))}
+	_, score, pos, _ := pattern.MatchScore(&item, true, slab)
+	scoreOnly := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("log"))
+	_, expected, expectedPos, _ := scoreOnly.MatchScore(&item, true, slab)
+	if score != expected || len(*pos) != len(*expectedPos) {
+		t.Errorf("Unexpected score or positions: %d / %v", score, pos)
+	}
+
+	// Comparison only patterns are not sorted
+	pattern = buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("{2}>10M"))
+	if pattern.sortable || pattern.cacheable {
+		t.Error("Expected unsortable and uncacheable pattern")
+	}
+}
+
+func TestSourceTerm(t *testing.T) {
+	// Not recognized without --source
+	if terms := parseTerms(true, CaseSmart, false, "source:br"); terms[0][0].source {
+		t.Errorf("%v", terms)
+	}
+	setSourceNames([]string{"branch", "tag"})
+	defer setSourceNames(nil)
+
+	terms := parseTerms(true, CaseSmart, false, "source:br !source:^tag source:/b.*h/ {1}source:x")
+	if len(terms) != 4 ||
+		!terms[0][0].source || terms[0][0].typ != termFuzzy || string(terms[0][0].text) != "br" ||
+		!terms[1][0].source || !terms[1][0].inv || terms[1][0].typ != termPrefix ||
+		!terms[2][0].source || terms[2][0].typ != termRegex ||
+		terms[3][0].source || len(terms[3][0].nth) != 1 {
+		t.Errorf("%v", terms)
+	}
+
+	pattern := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("source:bra main"))
+	if pattern.cacheable || pattern.CacheKey() != "main" || pattern.directAlgo != nil {
+		t.Errorf("Source terms should not be cached: %q", pattern.CacheKey())
+	}
+	for _, tc := range []struct {
+		text     string
+		source   uint8
+		expected bool
+	}{
+		{"main", 1, true},
+		{"main", 2, false},
+		{"main", 0, false},
+		{"bra", 2, false},
+	} {
+		item := Item{text: util.ToChars([]byte(tc.text))}
+		item.text.SetSource(tc.source)
+		if _, _, _, matched := pattern.MatchScore(&item, false, slab); matched != tc.expected {
+			t.Errorf("Expected %v for %q from %d", tc.expected, tc.text, tc.source)
+		}
+	}
+
+	// Source terms don't contribute to the score nor to the positions
+	item := Item{text: util.ToChars([]byte("main"))}
+	item.text.SetSource(1)
+	_, score, pos, _ := pattern.MatchScore(&item, true, slab)
+	scoreOnly := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("main"))
+	_, expected, expectedPos, _ := scoreOnly.MatchScore(&item, true, slab)
+	if score != expected || len(*pos) != len(*expectedPos) {
+		t.Errorf("Unexpected score or positions: %d / %v", score, pos)
+	}
+
+	// Source only patterns are not sorted
+	pattern = buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("!source:tag"))
+	if pattern.sortable || pattern.cacheable {
+		t.Error("Expected unsortable and uncacheable pattern")
+	}
+}
+
+func TestParseTermsRegex(t *testing.T) {
+	terms := parseTerms(true, CaseSmart, false, `/\d+-rc/ !/^a.c/ /[/ /A\dB/ // | /x|y/ /a\ b/`)
+	if len(terms) != 6 ||
+		terms[0][0].typ != termRegex || terms[0][0].inv || terms[0][0].caseSensitive ||
+		terms[1][0].typ != termRegex || !terms[1][0].inv ||
+		terms[2][0].typ != termFuzzy ||
+		terms[3][0].typ != termRegex || !terms[3][0].caseSensitive ||
+		terms[4][0].typ != termFuzzy || terms[4][1].typ != termRegex ||
+		terms[5][0].typ != termRegex || string(terms[5][0].text) != "a b" {
+		t.Errorf("%v", terms)
+	}
+	if string(terms[0][0].text) != `\d+-rc` || string(terms[3][0].text) != `A\dB` {
+		t.Errorf("%v", terms)
+	}
+}
+
+func TestRegexTerm(t *testing.T) {
+	pattern := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune(`/v\d+-rc/ !/beta/`))
+	if pattern.CacheKey() != regexCacheKeyPrefix+`v\d+-rc` {
+		t.Errorf("Unexpected cache key: %q", pattern.CacheKey())
+	}
+	for input, expected := range map[string]bool{
+		"fzf V12-RC":      true,
+		"fzf v1.2-rc":     false,
+		"fzf v12-rc beta": false,
+	} {
+		item := Item{text: util.ToChars([]byte(input))}
+		if _, _, _, matched := pattern.MatchScore(&item, false, slab); matched != expected {
+			t.Errorf("Expected %v for %q", expected, input)
+		}
+	}
+	item := Item{text: util.ToChars([]byte("fzf v12-rc"))}
+	if offsets, _, pos, _ := pattern.MatchScore(&item, true, slab); offsets[0] != (Offset{4, 10}) || len(*pos) != 6 {
+		t.Errorf("Unexpected match: %v / %v", offsets, pos)
+	}
+}
+
+func TestParseTermsGroup(t *testing.T) {
+	terms := parseTerms(true, CaseSmart, false, "(foo | ^bar) !(baz qux$) | x ((y))")
+	if len(terms) != 3 ||
+		terms[0][0].typ != termGroup || terms[0][0].inv || len(terms[0][0].group) != 1 ||
+		terms[0][0].group[0][0].typ != termFuzzy || terms[0][0].group[0][1].typ != termPrefix ||
+		terms[1][0].typ != termGroup || !terms[1][0].inv || len(terms[1][0].group) != 2 ||
+		terms[1][0].group[1][0].typ != termSuffix ||
+		terms[1][1].typ != termFuzzy || string(terms[1][1].text) != "x" ||
+		terms[2][0].typ != termGroup || terms[2][0].group[0][0].typ != termGroup {
+		t.Errorf("%v", terms)
+	}
+
+	// Unbalanced parentheses are treated as text
+	for _, query := range []string{"(foo bar", "foo)", "foo) (bar"} {
+		terms := parseTerms(true, CaseSmart, false, query)
+		for _, termSet := range terms {
+			for _, term := range termSet {
+				if term.typ == termGroup {
+					t.Errorf("Unexpected group in %q: %v", query, terms)
+				}
+			}
+		}
+	}
+
+	// Empty groups are ignored
+	if terms := parseTerms(true, CaseSmart, false, "() foo !()"); len(terms) != 1 || terms[0][0].typ != termFuzzy {
+		t.Errorf("%v", terms)
+	}
+}
+
+func TestGroupTerm(t *testing.T) {
+	pattern := buildPattern(true, algo.FuzzyMatchV2, true, CaseSmart, false, true, false, true,
+		[]Range{}, Delimiter{}, []rune("(foo | bar) !(baz qux) 'go"))
+	if pattern.cacheable || pattern.CacheKey() != "go" {
+		t.Errorf("Unexpected cache key: %q", pattern.CacheKey())
+	}
+	for input, expected := range map[string]bool{
+		"foo.go":         true,
+		"bar.go":         true,
+		"foo_baz.go":     true,
+		"bar_baz_qux.go": false,
+		"baz_qux.go":     false,
+		"foo.c":          false,
+	} {
+		item := Item{text: util.ToChars([]byte(input))}
+		if _, _, _, matched := pattern.MatchScore(&item, false, slab); matched != expected {
+			t.Errorf("Expected %v for %q", expected, input)
+		}
+	}
+	item := Item{text: util.ToChars([]byte("xbar.go"))}
+	if offsets, _, pos, _ := pattern.MatchScore(&item, true, slab); offsets[0] != (Offset{1, 4}) || len(*pos) != 5 {
+		t.Errorf("Unexpected match: %v / %v", offsets, pos)
+	}
+}
+
 func TestParseTermsExtendedExact(t *testing.T) {
 	terms := parseTerms(false, CaseSmart, false,
 		"aaa 'bbb ^ccc ddd$ !eee !'fff !^ggg !hhh$")
@@ -138,7 +400,7 @@ func TestOrigTextAndTransformed(t *testing.T) {
 			origText:    &origBytes,
 			transformed: &transformed{pattern.revision, trans}}
 		pattern.extended = extended
-		matches, _ := pattern.matchChunk(&chunk, nil, slab) // No cache
+		matches, _ := pattern.matchChunk(&chunk, nil, nil, slab) // No cache
 		if !(matches[0].item.text.ToString() == "junegunn" &&
 			string(*matches[0].item.origText) == "junegunn.choi" &&
 			reflect.DeepEqual((*matches[0].item.transformed).tokens, trans)) {
@@ -158,6 +420,24 @@ func TestOrigTextAndTransformed(t *testing.T) {
 	}
 }
 
+func TestMatchScore(t *testing.T) {
+	for _, extended := range []bool{false, true} {
+		pattern := buildPattern(true, algo.FuzzyMatchV2, extended, CaseSmart, false, true, false, true,
+			[]Range{}, Delimiter{}, []rune("abc"))
+		item := Item{text: util.ToChars([]byte("xabcx"))}
+		offsets, score, _, matched := pattern.MatchScore(&item, false, slab)
+		res, _ := algo.FuzzyMatchV2(false, false, true, &item.text, []rune("abc"), false, nil)
+		if !matched || score != res.Score || offsets[0] != (Offset{1, 4}) {
+			t.Errorf("Unexpected match: %v / %d / %v", matched, score, offsets)
+		}
+
+		item = Item{text: util.ToChars([]byte("xyz"))}
+		if _, _, _, matched := pattern.MatchScore(&item, false, slab); matched {
+			t.Error("Expected no match")
+		}
+	}
+}
+
 func TestCacheKey(t *testing.T) {
 	test := func(extended bool, patStr string, expected string, cacheable bool) {
 		pat := buildPattern(true, algo.FuzzyMatchV2, extended, CaseSmart, false, true, false, true, []Range{}, Delimiter{}, []rune(patStr))
@@ -193,6 +473,8 @@ func TestCacheable(t *testing.T) {
 	test(true, "foo bar", "foo\tbar", true)
 	test(true, "foo 'bar", "foo\tbar", false)
 	test(true, "foo !bar", "foo", false)
+	// Approximate match is not a subset of fuzzy match
+	test(true, "foo %bar", "foo", false)
 
 	test(false, "foo bar", "foo\tbar", true)
 	test(false, "foo 'bar", "foo", false)
@@ -287,6 +569,49 @@ func TestBitmapCacheBenefit(t *testing.T) {
 	}
 }
 
+func TestPerTermCache(t *testing.T) {
+	numChunks := 20
+	chunks := buildChunks(numChunks)
+	// Editing the middle term, reordering the terms, and mixing in the terms
+	// that are not cached individually
+	queries := []string{
+		"s", "se", "ser", "serv", "serv j", "serv ja", "serv ja com",
+		"serv jav com", "serv java com", "serv jv com", "serv j com",
+		"com serv j", "j com serv", "com !test serv", "/se.v/ com",
+		"com /se.v/", "^src serv com", "serv | go com", "com serv go",
+	}
+	cache := NewChunkCache()
+	for _, q := range queries {
+		pat := buildPatternWith(cache, []rune(q))
+		patFresh := buildPatternWith(NewChunkCache(), []rune(q))
+		var countCached, countFresh int
+		for _, chunk := range chunks {
+			countCached += len(pat.Match(chunk, slab))
+			countFresh += len(patFresh.Match(chunk, slab))
+		}
+		if countCached != countFresh {
+			t.Errorf("query=%q: cached=%d, fresh=%d", q, countCached, countFresh)
+		}
+	}
+
+	// The unchanged terms narrow down the items to scan
+	pat := buildPatternWith(cache, []rune("com serv jx"))
+	candidates := cache.SearchTerms(chunks[0], pat.termKeys)
+	if candidates == nil {
+		t.Fatal("Expected cached term results")
+	}
+	count := 0
+	for _, word := range candidates {
+		count += bits.OnesCount64(word)
+	}
+	// Only the items matching the unchanged terms and the prefix of the
+	// changed term are left
+	expected := len(buildPatternWith(NewChunkCache(), []rune("com serv j")).Match(chunks[0], slab))
+	if count != expected || count == chunkSize {
+		t.Errorf("Expected %d candidates, got %d", expected, count)
+	}
+}
+
 func BenchmarkWithCache(b *testing.B) {
 	numChunks := 100
 	chunks := buildChunks(numChunks)
@@ -316,3 +641,34 @@ func BenchmarkWithCache(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkPerTermCache(b *testing.B) {
+	numChunks := 100
+	chunks := buildChunks(numChunks)
+	// Editing the middle term of a multi-term query
+	queries := []string{"src java test", "src jav test", "src ja test", "src jva test", "src jvaa test"}
+
+	b.Run("cached", func(b *testing.B) {
+		for range b.N {
+			cache := NewChunkCache()
+			for _, q := range queries {
+				pat := buildPatternWith(cache, []rune(q))
+				for _, chunk := range chunks {
+					pat.Match(chunk, slab)
+				}
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for range b.N {
+			for _, q := range queries {
+				cache := NewChunkCache()
+				pat := buildPatternWith(cache, []rune(q))
+				for _, chunk := range chunks {
+					pat.Match(chunk, slab)
+				}
+			}
+		}
+	})
+}
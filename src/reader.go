@@ -5,9 +5,12 @@ import (
 	"context"
 	"io"
 	"io/fs"
+	"math"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,6 +33,11 @@ type Reader struct {
 	termFunc func()
 	command  *string
 	wait     bool
+	watch    *walkerWatch
+	remover  func([]int32) // Called with the indexes of the removed items
+
+	sourcePusher func([]byte, uint8) bool   // Pusher for the tagged input sources
+	indexPusher  func([]byte) (int32, bool) // Pusher returning the index of the item
 }
 
 // NewReader returns new Reader object
@@ -45,7 +53,11 @@ func NewReader(pusher func([]byte) bool, eventBox *util.EventBox, executor *util
 		false,
 		func() { os.Stdin.Close() },
 		nil,
-		wait}
+		wait,
+		nil,
+		nil,
+		nil,
+		nil}
 }
 
 func (r *Reader) startEventPoller() {
@@ -121,8 +133,19 @@ func (r *Reader) readChannel(inputChan chan string) bool {
 	return true
 }
 
+// appendItems feeds the items received by the server (POST /items) to the
+// list in the same way as the input source. The requests are served until
+// fzf exits, even after the input source is exhausted.
+func (r *Reader) appendItems(requests chan itemsRequest) {
+	for request := range requests {
+		r.feed(bytes.NewReader(request.data), request.delimNil, r.pusher)
+		r.eventBox.Set(EvtReadNew, (*string)(nil))
+		close(request.done)
+	}
+}
+
 // ReadSource reads data from the default command or from standard input
-func (r *Reader) ReadSource(inputChan chan string, roots []string, opts walkerOpts, ignores []string, initCmd string, initEnv []string, readyChan chan bool) {
+func (r *Reader) ReadSource(inputChan chan string, sources []inputSource, roots []string, opts walkerOpts, ignores []string, filter walkerFilter, format walkerFormat, watchDirs bool, initCmd string, initEnv []string, readyChan chan bool) {
 	r.startEventPoller()
 	var success bool
 	signalReady := func() {
@@ -135,11 +158,13 @@ func (r *Reader) ReadSource(inputChan chan string, roots []string, opts walkerOp
 		success = r.readChannel(inputChan)
 	} else if len(initCmd) > 0 {
 		success = r.readFromCommand(initCmd, initEnv, signalReady)
+	} else if len(sources) > 0 {
+		success = r.readFromSources(sources, initEnv, signalReady)
 	} else if util.IsTty(os.Stdin) {
 		cmd := os.Getenv("FZF_DEFAULT_COMMAND")
 		if len(cmd) == 0 {
 			signalReady()
-			success = r.readFiles(roots, opts, ignores)
+			success = r.readFiles(roots, opts, ignores, filter, format, watchDirs)
 		} else {
 			success = r.readFromCommand(cmd, initEnv, signalReady)
 		}
@@ -148,9 +173,48 @@ func (r *Reader) ReadSource(inputChan chan string, roots []string, opts walkerOp
 		success = r.readFromStdin()
 	}
 	r.fin(success)
+
+	r.mutex.Lock()
+	watch := r.watch
+	r.mutex.Unlock()
+	if watch != nil {
+		go watch.run()
+	}
+}
+
+// setSourcePusher sets the function to push the items of the input sources
+// with the 1-based index of the source. Required to read from the sources.
+func (r *Reader) setSourcePusher(pusher func([]byte, uint8) bool) {
+	r.sourcePusher = pusher
+}
+
+// setIndexPusher sets the function to push the items with their indexes
+// returned. Required to watch the walked directories.
+func (r *Reader) setIndexPusher(pusher func([]byte) (int32, bool)) {
+	r.indexPusher = pusher
 }
 
-func (r *Reader) feed(src io.Reader) {
+// setRemover sets the function to exclude the items of the entries removed
+// from the watched directories
+func (r *Reader) setRemover(remover func([]int32)) {
+	r.mutex.Lock()
+	r.remover = remover
+	r.mutex.Unlock()
+}
+
+// unwatch stops updating the walker results with the changes in the
+// directories
+func (r *Reader) unwatch() {
+	r.mutex.Lock()
+	watch := r.watch
+	r.watch = nil
+	r.mutex.Unlock()
+	if watch != nil {
+		watch.close()
+	}
+}
+
+func (r *Reader) feed(src io.Reader, delimNil bool, pusher func([]byte) bool) {
 	/*
 		readerSlabSize, ae := strconv.Atoi(os.Getenv("SLAB_KB"))
 		if ae != nil {
@@ -168,7 +232,7 @@ func (r *Reader) feed(src io.Reader) {
 
 	delim := byte('\n')
 	trimCR := util.IsWindows()
-	if r.delimNil {
+	if delimNil {
 		delim = '\000'
 		trimCR = false
 	}
@@ -208,7 +272,7 @@ func (r *Reader) feed(src io.Reader) {
 					slice = append(leftover, slice...)
 					leftover = []byte{}
 				}
-				if (err == nil || len(slice) > 0) && r.pusher(slice) {
+				if (err == nil || len(slice) > 0) && pusher(slice) {
 					atomic.StoreInt32(&r.event, int32(EvtReadNew))
 				}
 			} else {
@@ -233,13 +297,13 @@ func (r *Reader) feed(src io.Reader) {
 			slab = make([]byte, readerSlabSize)
 		}
 	}
-	if len(leftover) > 0 && r.pusher(leftover) {
+	if len(leftover) > 0 && pusher(leftover) {
 		atomic.StoreInt32(&r.event, int32(EvtReadNew))
 	}
 }
 
 func (r *Reader) readFromStdin() bool {
-	r.feed(os.Stdin)
+	r.feed(os.Stdin, r.delimNil, r.pusher)
 	return true
 }
 
@@ -267,12 +331,123 @@ func trimPath(path string) string {
 	return byteString(bytes)
 }
 
-func (r *Reader) readFiles(roots []string, opts walkerOpts, ignores []string) bool {
+// stats returns true if the filter needs the metadata of the files
+func (f *walkerFilter) stats() bool {
+	return f.minSize > 0 || f.maxSize > 0 || !f.newer.IsZero()
+}
+
+// match returns true if the file satisfies the conditions of the filter
+func (f *walkerFilter) match(path string, de os.DirEntry) bool {
+	if len(f.exts) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+		if !slices.Contains(f.exts, ext) {
+			return false
+		}
+	}
+	if !f.stats() {
+		return true
+	}
+	var info os.FileInfo
+	var err error
+	if de.Type()&os.ModeSymlink != 0 {
+		info, err = os.Stat(path)
+	} else {
+		info, err = de.Info()
+	}
+	if err != nil {
+		return false
+	}
+	if info.Size() < f.minSize || f.maxSize > 0 && info.Size() > f.maxSize {
+		return false
+	}
+	return f.newer.IsZero() || !info.ModTime().Before(f.newer)
+}
+
+// render returns the line of the entry in the format. The metadata of the
+// entry is only read when the format needs it.
+func (f walkerFormat) render(path string, de os.DirEntry, isDir bool) string {
+	var info os.FileInfo
+	stat := func() os.FileInfo {
+		if info == nil {
+			var err error
+			if de.Type()&os.ModeSymlink != 0 {
+				info, err = os.Stat(path)
+			} else {
+				info, err = de.Info()
+			}
+			if err != nil {
+				info = nil
+			}
+		}
+		return info
+	}
+	var builder strings.Builder
+	for _, part := range f {
+		switch part.field {
+		case "":
+			builder.WriteString(part.text)
+		case "path":
+			builder.WriteString(path)
+		case "name":
+			builder.WriteString(filepath.Base(path))
+		case "type":
+			switch {
+			case de.Type()&os.ModeSymlink != 0:
+				builder.WriteByte('l')
+			case isDir:
+				builder.WriteByte('d')
+			case de.Type().IsRegular():
+				builder.WriteByte('f')
+			default:
+				builder.WriteByte('o')
+			}
+		case "size", "hsize", "mtime", "mode":
+			info := stat()
+			if info == nil {
+				builder.WriteByte('-')
+				break
+			}
+			switch part.field {
+			case "size":
+				builder.WriteString(strconv.FormatInt(info.Size(), 10))
+			case "hsize":
+				builder.WriteString(formatSize(info.Size()))
+			case "mtime":
+				builder.WriteString(info.ModTime().Format("2006-01-02T15:04:05"))
+			case "mode":
+				builder.WriteString(info.Mode().String())
+			}
+		}
+	}
+	return builder.String()
+}
+
+// formatSize returns the human-readable form of the size in powers of 1024
+// as in the output of `ls -h`. It can be compared with comparison terms.
+func formatSize(size int64) string {
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len("KMGTP") {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatInt(size, 10)
+	}
+	suffix := "KMGTP"[unit-1 : unit]
+	if rounded := math.Ceil(value*10) / 10; rounded < 10 {
+		return strconv.FormatFloat(rounded, 'f', 1, 64) + suffix
+	}
+	return strconv.FormatFloat(math.Ceil(value), 'f', 0, 64) + suffix
+}
+
+func (r *Reader) readFiles(roots []string, opts walkerOpts, ignores []string, filter walkerFilter, format walkerFormat, watchDirs bool) bool {
 	conf := fastwalk.Config{
 		Follow: opts.follow,
 		// Use forward slashes when running a Windows binary under WSL or MSYS
-		ToSlash: fastwalk.DefaultToSlash(),
-		Sort:    fastwalk.SortFilesFirst,
+		ToSlash:  fastwalk.DefaultToSlash(),
+		Sort:     fastwalk.SortFilesFirst,
+		MaxDepth: filter.maxDepth,
 	}
 
 	// When following symlinks, precompute the absolute real paths of walker
@@ -315,6 +490,27 @@ func (r *Reader) readFiles(roots []string, opts walkerOpts, ignores []string) bo
 			ignoresBase = append(ignoresBase, ignore)
 		}
 	}
+	// Patterns in .gitignore, .ignore, and .git/info/exclude files
+	var gitignore *gitIgnore
+	if opts.gitignore {
+		gitignore = newGitIgnore()
+	}
+
+	// Watch the walked directories to keep the results up to date
+	var watch *walkerWatch
+	if watchDirs && r.indexPusher != nil {
+		if watcher, err := newDirWatcher(); err == nil {
+			watch = newWalkerWatch(watcher)
+		}
+	}
+	// Depth of the directory walked after it's created
+	depthOffset := 0
+	render := func(path string, de os.DirEntry, isDir bool) string {
+		if len(format) > 0 {
+			return format.render(path, de, isDir)
+		}
+		return path
+	}
 	fn := func(path string, de os.DirEntry, err error) error {
 		if err != nil {
 			return nil
@@ -360,12 +556,42 @@ func (r *Reader) readFiles(roots []string, opts walkerOpts, ignores []string) bo
 						return filepath.SkipDir
 					}
 				}
-				if path != sep {
-					path += sep
+			}
+			if gitignore != nil {
+				if gitignore.ignored(path, isDir) {
+					if isDir {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if isDir {
+					gitignore.enter(path)
 				}
 			}
-			if ((opts.file && !isDir) || (opts.dir && isDir)) && r.pusher(stringBytes(path)) {
-				atomic.StoreInt32(&r.event, int32(EvtReadNew))
+			key := path
+			if watch != nil && isDir {
+				depth := depthOffset + max(fastwalk.DirEntryDepth(de), 0)
+				if filter.maxDepth == 0 || depth < filter.maxDepth {
+					watch.watcher.add(path, depth)
+				}
+			}
+			if isDir && path != sep {
+				path += sep
+			}
+			if opts.file && !isDir && !filter.match(path, de) {
+				return nil
+			}
+			if (opts.file && !isDir) || (opts.dir && isDir) {
+				line := render(path, de, isDir)
+				var pushed bool
+				if watch != nil {
+					pushed = watch.push(key, line, r.indexPusher)
+				} else {
+					pushed = r.pusher(stringBytes(line))
+				}
+				if pushed {
+					atomic.StoreInt32(&r.event, int32(EvtReadNew))
+				}
 			}
 		}
 		r.mutex.Lock()
@@ -377,8 +603,81 @@ func (r *Reader) readFiles(roots []string, opts walkerOpts, ignores []string) bo
 	}
 	noerr := true
 	for _, root := range roots {
+		if gitignore != nil {
+			gitignore.enterRoot(root)
+		}
+		if watch != nil && trimPath(root) == "." {
+			watch.watcher.add(".", 0)
+		}
 		noerr = noerr && (fastwalk.Walk(&conf, root, fn) == nil)
 	}
+
+	if watch != nil {
+		watch.handler = func(events []watchEvent) {
+			removed := []int32{}
+			// Items of the removed entries should be excluded before the
+			// entries with the same paths are created again
+			flush := func() {
+				r.mutex.Lock()
+				remover := r.remover
+				r.mutex.Unlock()
+				if len(removed) > 0 && remover != nil && !watch.isClosed() {
+					remover(removed)
+				}
+				removed = removed[:0]
+			}
+			for _, event := range events {
+				path := event.name
+				if event.dir != "." {
+					path = strings.TrimSuffix(event.dir, sep) + sep + event.name
+				}
+				if event.removed {
+					if event.isDir {
+						watch.watcher.removeTree(path, sep)
+					}
+					removed = append(removed, watch.forget(path, event.isDir, sep)...)
+					continue
+				}
+				if event.modified && (event.isDir || len(format) == 0 && !filter.stats()) {
+					// Nothing to update unless the line or the filter depends on
+					// the metadata of the file
+					continue
+				}
+				info, err := os.Lstat(path)
+				if err != nil {
+					continue
+				}
+				if event.modified {
+					de := fs.FileInfoToDirEntry(info)
+					if line, found := watch.line(path); found && filter.match(path, de) && render(path, de, false) == line {
+						continue
+					}
+					// Replace the item of the file with the updated one, if any
+					removed = append(removed, watch.forget(path, false, sep)...)
+				}
+				flush()
+				depthOffset = event.depth
+				if info.IsDir() && (filter.maxDepth == 0 || event.depth < filter.maxDepth) {
+					sub := conf
+					if filter.maxDepth > 0 {
+						sub.MaxDepth = filter.maxDepth - event.depth
+					}
+					fastwalk.Walk(&sub, path, fn)
+				} else {
+					fn(path, fs.FileInfoToDirEntry(info), nil)
+				}
+			}
+			flush()
+			r.eventBox.Set(EvtReadNew, (*string)(nil))
+		}
+		r.mutex.Lock()
+		if r.killed {
+			watch.close()
+		} else {
+			r.watch = watch
+		}
+		r.mutex.Unlock()
+	}
 	return noerr
 }
 
@@ -408,6 +707,73 @@ func (r *Reader) readFromCommand(command string, environ []string, signalReady f
 	signalReady()
 	r.mutex.Unlock()
 
-	r.feed(execOut)
+	r.feed(execOut, r.delimNil, r.pusher)
 	return exec.Wait() == nil
 }
+
+// readFromSources runs the commands of the input sources concurrently and
+// tags the items with the index of the source. Returns false if any of the
+// commands fails, in which case r.command is set to the failed one.
+func (r *Reader) readFromSources(sources []inputSource, environ []string, signalReady func()) bool {
+	r.mutex.Lock()
+
+	r.killed = false
+	r.termFunc = nil
+	r.command = nil
+	execs := make([]*exec.Cmd, 0, len(sources))
+	outs := make([]io.ReadCloser, 0, len(sources))
+	terminate := func() {
+		for idx, exec := range execs {
+			outs[idx].Close()
+			util.KillCommand(exec)
+		}
+	}
+	for _, source := range sources {
+		exec := r.executor.ExecCommand(source.command, true)
+		if environ != nil {
+			exec.Env = environ
+		}
+		execOut, err := exec.StdoutPipe()
+		if err != nil || exec.Start() != nil {
+			terminate()
+			for _, started := range execs {
+				started.Wait()
+			}
+			r.command = &source.command
+			signalReady()
+			r.mutex.Unlock()
+			return false
+		}
+		execs = append(execs, exec)
+		outs = append(outs, execOut)
+	}
+
+	// Function to call to terminate the running commands
+	r.termFunc = terminate
+
+	signalReady()
+	r.mutex.Unlock()
+
+	success := true
+	var wg sync.WaitGroup
+	for idx := range execs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source := uint8(idx + 1)
+			r.feed(outs[idx], r.delimNil, func(data []byte) bool {
+				return r.sourcePusher(data, source)
+			})
+			if execs[idx].Wait() != nil {
+				r.mutex.Lock()
+				if success {
+					success = false
+					r.command = &sources[idx].command
+				}
+				r.mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return success
+}
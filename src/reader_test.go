@@ -1,6 +1,12 @@
 package fzf
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -66,3 +72,332 @@ func TestReadFromCommand(t *testing.T) {
 		t.Error("EvtReadFin should be set")
 	}
 }
+
+func TestReadFromSources(t *testing.T) {
+	var mutex sync.Mutex
+	strs := []string{}
+	eb := util.NewEventBox()
+	reader := NewReader(
+		func(s []byte) bool { t.Error("Untagged item: " + string(s)); return true },
+		eb, util.NewExecutor(""), false, true)
+	reader.setSourcePusher(func(s []byte, source uint8) bool {
+		mutex.Lock()
+		strs = append(strs, fmt.Sprintf("%d:%s", source, s))
+		mutex.Unlock()
+		return true
+	})
+
+	reader.startEventPoller()
+	counter := 0
+	ready := func() {
+		counter++
+	}
+	sources := []inputSource{{"a", "echo abc&&echo def"}, {"b", "echo ghi"}}
+	reader.fin(reader.readFromSources(sources, nil, ready))
+	slices.Sort(strs)
+	expected := []string{"1:abc", "1:def", "2:ghi"}
+	if !slices.Equal(strs, expected) || counter != 1 {
+		t.Errorf("Expected %q, got %q", expected, strs)
+	}
+	eb.WaitFor(EvtReadFin)
+	eb.Wait(func(events *util.Events) {
+		if value := (*events)[EvtReadFin]; value.(*string) != nil {
+			t.Errorf("Unexpected failed command: %s", *value.(*string))
+		}
+		events.Clear()
+	})
+
+	// The failed command is reported
+	time.Sleep(readerPollIntervalMax)
+	reader.startEventPoller()
+	sources = []inputSource{{"a", "echo abc"}, {"b", "echo ghi&&exit 1"}}
+	reader.fin(reader.readFromSources(sources, nil, ready))
+	eb.WaitFor(EvtReadFin)
+	eb.Wait(func(events *util.Events) {
+		if value := (*events)[EvtReadFin]; value.(*string) == nil || *value.(*string) != sources[1].command {
+			t.Errorf("Expected the failed command to be reported: %v", value)
+		}
+		events.Clear()
+	})
+}
+
+func TestAppendItems(t *testing.T) {
+	strs := []string{}
+	eb := util.NewEventBox()
+	reader := NewReader(
+		func(s []byte) bool { strs = append(strs, string(s)); return true },
+		eb, util.NewExecutor(""), false, true)
+
+	requests := make(chan itemsRequest)
+	go reader.appendItems(requests)
+	for _, request := range []itemsRequest{
+		{data: []byte("abc\ndef\n")},
+		{data: []byte("ghi\x00jkl\nmno"), delimNil: true},
+	} {
+		request.done = make(chan struct{})
+		requests <- request
+		<-request.done
+	}
+	close(requests)
+
+	expected := []string{"abc", "def", "ghi", "jkl\nmno"}
+	if len(strs) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, strs)
+	}
+	for i, str := range strs {
+		if str != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], str)
+		}
+	}
+	if !eb.Peek(EvtReadNew) {
+		t.Error("EvtReadNew should be set")
+	}
+}
+
+func TestReadFilesFilter(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-72 * time.Hour)
+	for name, size := range map[string]int{
+		"a.go":        10,
+		"b.md":        2000,
+		"c.txt":       10,
+		"d/e.go":      3000,
+		"d/f/g.GO":    10,
+		"d/f/h/i.go":  10,
+		"old.go":      10,
+		"no-ext-file": 10,
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if name == "old.go" {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	walk := func(opts walkerOpts, filter walkerFilter) []string {
+		var mutex sync.Mutex
+		paths := []string{}
+		reader := NewReader(func(s []byte) bool {
+			// Ignore the root directory itself
+			if path := strings.TrimPrefix(filepath.ToSlash(string(s)), filepath.ToSlash(root)+"/"); len(path) > 0 {
+				mutex.Lock()
+				paths = append(paths, path)
+				mutex.Unlock()
+			}
+			return true
+		}, util.NewEventBox(), util.NewExecutor(""), false, true)
+		reader.readFiles([]string{root}, opts, nil, filter, nil, false)
+		slices.Sort(paths)
+		return paths
+	}
+
+	for _, tc := range []struct {
+		filter   walkerFilter
+		dir      bool
+		expected []string
+	}{
+		{walkerFilter{maxDepth: 2}, false, []string{"a.go", "b.md", "c.txt", "d/e.go", "no-ext-file", "old.go"}},
+		{walkerFilter{maxDepth: 1}, true, []string{"a.go", "b.md", "c.txt", "d/", "no-ext-file", "old.go"}},
+		{walkerFilter{exts: []string{"go"}}, false, []string{"a.go", "d/e.go", "d/f/g.GO", "d/f/h/i.go", "old.go"}},
+		{walkerFilter{exts: []string{"go"}}, true, []string{"a.go", "d/", "d/e.go", "d/f/", "d/f/g.GO", "d/f/h/", "d/f/h/i.go", "old.go"}},
+		{walkerFilter{minSize: 1000}, false, []string{"b.md", "d/e.go"}},
+		{walkerFilter{maxSize: 2000}, false, []string{"a.go", "b.md", "c.txt", "d/f/g.GO", "d/f/h/i.go", "no-ext-file", "old.go"}},
+		{walkerFilter{exts: []string{"go"}, newer: old.Add(time.Hour)}, false, []string{"a.go", "d/e.go", "d/f/g.GO", "d/f/h/i.go"}},
+	} {
+		paths := walk(walkerOpts{file: true, dir: tc.dir}, tc.filter)
+		if !slices.Equal(paths, tc.expected) {
+			t.Errorf("%+v: expected %v, got %v", tc.filter, tc.expected, paths)
+		}
+	}
+}
+
+func TestReadFilesFormat(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "foo.txt")
+	if err := os.WriteFile(path, make([]byte, 1536), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(path, 0644)
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	os.Chtimes(path, mtime, mtime)
+	os.Mkdir(filepath.Join(root, "bar"), 0755)
+
+	format, _ := parseWalkerFormat(`{type} {size} {hsize} {mtime} {mode} {name}\t{path}`)
+	lines := []string{}
+	var mutex sync.Mutex
+	reader := NewReader(func(s []byte) bool {
+		mutex.Lock()
+		lines = append(lines, string(s))
+		mutex.Unlock()
+		return true
+	}, util.NewEventBox(), util.NewExecutor(""), false, true)
+	reader.readFiles([]string{root}, walkerOpts{file: true}, nil, walkerFilter{}, format, false)
+	expected := []string{"f 1536 1.5K 2024-01-02T03:04:05 -rw-r--r-- foo.txt\t" + path}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expected: %q, actual: %q", expected, lines)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, expected := range map[int64]string{
+		0:                  "0",
+		1023:               "1023",
+		1024:               "1.0K",
+		1536:               "1.5K",
+		10 * 1024:          "10K",
+		10*1024*1024 - 1:   "10M",
+		3 * 1024 * 1024:    "3.0M",
+		1024 * 1024 * 1024: "1.0G",
+	} {
+		if actual := formatSize(size); actual != expected {
+			t.Errorf("%d: expected %s, actual %s", size, expected, actual)
+		}
+		if value, ok := parseNumber(formatSize(size)); !ok || value < float64(size) {
+			t.Errorf("%d: %s should be parsed as a number not smaller than the size", size, formatSize(size))
+		}
+	}
+}
+
+func TestReadFilesWatch(t *testing.T) {
+	if !walkerWatchSupported {
+		t.Skip("--walker-watch is not supported")
+	}
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a"), nil, 0600)
+
+	var mutex sync.Mutex
+	pushed := []string{}
+	removed := []string{}
+	rel := func(s string) string {
+		return strings.TrimPrefix(filepath.ToSlash(s), filepath.ToSlash(root)+"/")
+	}
+	reader := NewReader(func(s []byte) bool {
+		return false
+	}, util.NewEventBox(), util.NewExecutor(""), false, true)
+	reader.setIndexPusher(func(s []byte) (int32, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, rel(string(s)))
+		return int32(len(pushed) - 1), true
+	})
+	reader.setRemover(func(indexes []int32) {
+		mutex.Lock()
+		for _, index := range indexes {
+			removed = append(removed, pushed[index])
+		}
+		mutex.Unlock()
+	})
+	reader.readFiles([]string{root}, walkerOpts{file: true}, []string{".git"}, walkerFilter{}, nil, true)
+	if reader.watch == nil {
+		t.Fatal("Directories should be watched")
+	}
+	go reader.watch.run()
+	defer reader.unwatch()
+
+	wait := func(expectedPushed []string, expectedRemoved []string) {
+		t.Helper()
+		var p, r []string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			mutex.Lock()
+			p, r = slices.Sorted(slices.Values(pushed)), slices.Sorted(slices.Values(removed))
+			mutex.Unlock()
+			if slices.Equal(p, expectedPushed) && slices.Equal(r, expectedRemoved) {
+				return
+			}
+		}
+		t.Fatalf("expected: %v / %v, actual: %v / %v", expectedPushed, expectedRemoved, p, r)
+	}
+
+	wait([]string{"a"}, []string{})
+	os.WriteFile(filepath.Join(root, "b"), nil, 0600)
+	wait([]string{"a", "b"}, []string{})
+	os.Remove(filepath.Join(root, "a"))
+	wait([]string{"a", "b"}, []string{"a"})
+
+	// Files in the new directories are added, and hidden directories are skipped
+	os.MkdirAll(filepath.Join(root, "c", "d"), 0700)
+	os.WriteFile(filepath.Join(root, "c", "d", "e"), nil, 0600)
+	os.MkdirAll(filepath.Join(root, ".f"), 0700)
+	os.WriteFile(filepath.Join(root, ".f", "g"), nil, 0600)
+	wait([]string{"a", "b", "c/d/e"}, []string{"a"})
+
+	// Moving a directory away removes the files under it
+	os.Rename(filepath.Join(root, "c"), filepath.Join(root, ".h"))
+	wait([]string{"a", "b", "c/d/e"}, []string{"a", "c/d/e"})
+
+	// Nothing is added after unwatch
+	reader.unwatch()
+	os.WriteFile(filepath.Join(root, "i"), nil, 0600)
+	time.Sleep(100 * time.Millisecond)
+	wait([]string{"a", "b", "c/d/e"}, []string{"a", "c/d/e"})
+}
+
+func TestReadFilesWatchModified(t *testing.T) {
+	if !walkerWatchSupported {
+		t.Skip("--walker-watch is not supported")
+	}
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a"), nil, 0600)
+	os.WriteFile(filepath.Join(root, "b"), []byte("bb"), 0600)
+
+	var mutex sync.Mutex
+	pushed := []string{}
+	removed := []string{}
+	rel := func(s string) string {
+		return strings.TrimPrefix(filepath.ToSlash(s), filepath.ToSlash(root)+"/")
+	}
+	reader := NewReader(func(s []byte) bool {
+		return false
+	}, util.NewEventBox(), util.NewExecutor(""), false, true)
+	reader.setIndexPusher(func(s []byte) (int32, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, rel(string(s)))
+		return int32(len(pushed) - 1), true
+	})
+	reader.setRemover(func(indexes []int32) {
+		mutex.Lock()
+		for _, index := range indexes {
+			removed = append(removed, pushed[index])
+		}
+		mutex.Unlock()
+	})
+	format, _ := parseWalkerFormat("{path} {size}")
+	reader.readFiles([]string{root}, walkerOpts{file: true}, []string{".git"}, walkerFilter{minSize: 1}, format, true)
+	if reader.watch == nil {
+		t.Fatal("Directories should be watched")
+	}
+	go reader.watch.run()
+	defer reader.unwatch()
+
+	wait := func(expectedPushed []string, expectedRemoved []string) {
+		t.Helper()
+		var p, r []string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			mutex.Lock()
+			p, r = slices.Sorted(slices.Values(pushed)), slices.Sorted(slices.Values(removed))
+			mutex.Unlock()
+			if slices.Equal(p, expectedPushed) && slices.Equal(r, expectedRemoved) {
+				return
+			}
+		}
+		t.Fatalf("expected: %v / %v, actual: %v / %v", expectedPushed, expectedRemoved, p, r)
+	}
+
+	wait([]string{"b 2"}, []string{})
+
+	// The file that grows past the minimum size is added
+	os.WriteFile(filepath.Join(root, "a"), []byte("a"), 0600)
+	wait([]string{"a 1", "b 2"}, []string{})
+
+	// The line of the modified file is updated
+	os.WriteFile(filepath.Join(root, "b"), []byte("bbb"), 0600)
+	wait([]string{"a 1", "b 2", "b 3"}, []string{"b 2"})
+
+	// The file that shrinks below the minimum size is removed
+	os.WriteFile(filepath.Join(root, "a"), nil, 0600)
+	wait([]string{"a 1", "b 2", "b 3"}, []string{"a 1", "b 2"})
+}
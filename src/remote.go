@@ -0,0 +1,129 @@
+package fzf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const remoteTimeout = 10 * time.Second
+
+// remoteRequest is a request to the --listen server of a running fzf
+// instance made by --remote and --remote-status
+type remoteRequest struct {
+	address *listenAddress // nil to use $FZF_SOCK or $FZF_PORT
+	actions string         // empty for --remote-status
+}
+
+// remoteAddress returns the address of the server to connect to. If not
+// specified, it's taken from the environment variables exported by fzf to
+// the child processes.
+func (r *remoteRequest) remoteAddress() (listenAddress, error) {
+	if r.address != nil {
+		return *r.address, nil
+	}
+	if sock := os.Getenv("FZF_SOCK"); len(sock) > 0 {
+		return listenAddress{"", 0, sock}, nil
+	}
+	if port := os.Getenv("FZF_PORT"); len(port) > 0 {
+		return parseListenAddress(port)
+	}
+	return defaultListenAddr, errors.New("server address not specified (use --remote=ADDR or set $FZF_PORT or $FZF_SOCK)")
+}
+
+// RunRemote sends the request of --remote or --remote-status to a running
+// fzf instance and prints the response to stdout
+func RunRemote(opts *Options) (int, error) {
+	address, err := opts.Remote.remoteAddress()
+	if err != nil {
+		return ExitError, err
+	}
+	body, err := sendRemoteRequest(address, opts.Remote)
+	if err != nil {
+		return ExitError, err
+	}
+	if len(body) > 0 {
+		fmt.Print(body)
+	}
+	return ExitOk, nil
+}
+
+// sendRemoteRequest sends the request to the server and returns the body of
+// the response. A response with a status code other than 200 is returned as
+// an error.
+func sendRemoteRequest(address listenAddress, request *remoteRequest) (string, error) {
+	var conn net.Conn
+	var err error
+	if len(address.sock) > 0 {
+		conn, err = net.DialTimeout("unix", address.sock, remoteTimeout)
+	} else {
+		if address.port == 0 {
+			return "", errors.New("port number required")
+		}
+		conn, err = net.DialTimeout("tcp", net.JoinHostPort(address.host, strconv.Itoa(address.port)), remoteTimeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(remoteTimeout))
+
+	apiKey := os.Getenv("FZF_API_KEY")
+	if len(apiKey) == 0 {
+		apiKey = os.Getenv("FZF_API_KEY_READONLY")
+	}
+	headers := "Host: localhost" + crlf + "Connection: close" + crlf
+	if len(apiKey) > 0 {
+		headers += "X-Api-Key: " + apiKey + crlf
+	}
+	var message string
+	if len(request.actions) == 0 {
+		message = "GET / HTTP/1.1" + crlf + headers + crlf
+	} else {
+		message = "POST / HTTP/1.1" + crlf + headers +
+			fmt.Sprintf("Content-Length: %d", len(request.actions)) + crlf + crlf + request.actions
+	}
+	if _, err := conn.Write([]byte(message)); err != nil {
+		return "", err
+	}
+
+	// Status line and headers
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil {
+		return "", errors.New("no response from server")
+	}
+	parts := strings.SplitN(strings.TrimRight(status, crlf), " ", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "HTTP/") {
+		return "", errors.New("invalid response from server")
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", errors.New("invalid response from server")
+		}
+		if len(strings.TrimRight(line, crlf)) == 0 {
+			break
+		}
+	}
+
+	// The server closes the connection after the response
+	bytes, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	body := string(bytes)
+	if !strings.HasPrefix(parts[1], "200 ") {
+		if message := strings.TrimSpace(body); len(message) > 0 {
+			return "", fmt.Errorf("%s: %s", parts[1], message)
+		}
+		return "", errors.New(parts[1])
+	}
+	return body, nil
+}
@@ -0,0 +1,66 @@
+package fzf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSendRemoteRequest(t *testing.T) {
+	t.Setenv("FZF_API_KEY", "secret")
+	actionChannel := make(chan serverRequest, 10)
+	listener, port, err := startHttpServer(defaultListenAddr, httpServer{
+		actionChannel: actionChannel,
+		getHandler:    func(getParams) string { return `{"query":"foo"}` },
+		eventStream:   newEventStream(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	address := listenAddress{"localhost", port, ""}
+
+	body, err := sendRemoteRequest(address, &remoteRequest{})
+	if err != nil || body != "{\"query\":\"foo\"}\n" {
+		t.Errorf("Unexpected response: %q, %v", body, err)
+	}
+
+	if _, err := sendRemoteRequest(address, &remoteRequest{actions: "change-query(bar)+first"}); err != nil {
+		t.Error(err)
+	}
+	if request := <-actionChannel; len(request.actions) != 2 || request.actions[0].a != "bar" {
+		t.Errorf("Unexpected request: %v", request)
+	}
+
+	_, err = sendRemoteRequest(address, &remoteRequest{actions: "bogus"})
+	if err == nil || !strings.HasPrefix(err.Error(), "400 Bad Request: ") {
+		t.Errorf("Expected error: %v", err)
+	}
+
+	t.Setenv("FZF_API_KEY", "wrong")
+	if _, err := sendRemoteRequest(address, &remoteRequest{}); err == nil || !strings.HasPrefix(err.Error(), "401 ") {
+		t.Errorf("Expected error: %v", err)
+	}
+}
+
+func TestRemoteAddress(t *testing.T) {
+	t.Setenv("FZF_SOCK", "")
+	t.Setenv("FZF_PORT", "")
+	if _, err := (&remoteRequest{}).remoteAddress(); err == nil {
+		t.Error("Expected error")
+	}
+
+	t.Setenv("FZF_PORT", "6266")
+	if addr, _ := (&remoteRequest{}).remoteAddress(); addr != (listenAddress{"localhost", 6266, ""}) {
+		t.Errorf("Unexpected address: %v", addr)
+	}
+
+	t.Setenv("FZF_SOCK", "/tmp/fzf.sock")
+	if addr, _ := (&remoteRequest{}).remoteAddress(); addr != (listenAddress{"", 0, "/tmp/fzf.sock"}) {
+		t.Errorf("Unexpected address: %v", addr)
+	}
+
+	given := listenAddress{"localhost", 1234, ""}
+	if addr, _ := (&remoteRequest{address: &given}).remoteAddress(); addr != given {
+		t.Errorf("Unexpected address: %v", addr)
+	}
+}
@@ -1,9 +1,11 @@
 package fzf
 
 import (
+	"cmp"
 	"math"
 	"slices"
 	"sort"
+	"strings"
 	"unicode"
 
 	"github.com/junegunn/fzf/src/tui"
@@ -56,7 +58,9 @@ func buildResultFromBounds(item *Item, score int, minBegin, minEnd, maxEnd int,
 	result := Result{item: item}
 	numChars := item.text.Length()
 
-	for idx, criterion := range sortCriteria {
+	slot := len(result.points) - 1
+	fieldIdx := 0
+	for _, criterion := range sortCriteria {
 		val := uint16(math.MaxUint16)
 		switch criterion {
 		case byScore:
@@ -80,6 +84,22 @@ func buildResultFromBounds(item *Item, score int, minBegin, minEnd, maxEnd int,
 			}
 		case byLength:
 			val = item.TrimLength()
+		case byFrecency:
+			// Higher is better
+			val = math.MaxUint16 - util.AsUint16(frecency.cachedScore(item))
+		case bySource:
+			// In the order of --source options. Items not from any of the
+			// sources come last.
+			if source := item.text.Source(); source > 0 {
+				val = uint16(source)
+			}
+		case byField:
+			// Upper 16 bits in the current slot, lower 16 bits in the next
+			key := sortFields[fieldIdx].rank(item)
+			fieldIdx++
+			result.points[slot] = uint16(key >> 16)
+			slot--
+			val = uint16(key)
 		case byPathname:
 			if validOffsetFound {
 				lastDelim := -1
@@ -111,15 +131,124 @@ func buildResultFromBounds(item *Item, score int, minBegin, minEnd, maxEnd int,
 				}
 			}
 		}
-		result.points[3-idx] = val
+		result.points[slot] = val
+		slot--
+	}
+
+	return result
+}
+
+// value returns the text of the field of the item for the field tiebreak
+func (c fieldCriterion) value(item *Item) string {
+	tokens := Transform(Tokenize(item.text.ToString(), sortDelimiter), []Range{c.field})
+	return strings.TrimSpace(StripLastDelimiter(JoinTokens(tokens), sortDelimiter))
+}
+
+// numericValue parses the field as a number or a date
+func numericValue(str string) (float64, bool) {
+	if value, ok := parseNumber(str); ok {
+		return value, true
+	}
+	return parseDate(str)
+}
+
+// rank returns the sort key of the item for the field tiebreak. Only the
+// first four bytes of the field are compared as text, and the numbers are
+// compared in single precision, so the items with the same key are compared
+// again with compare.
+func (c fieldCriterion) rank(item *Item) uint32 {
+	str := c.value(item)
+	var key uint32
+	if c.numeric {
+		value, ok := numericValue(str)
+		// Items without a number come last
+		if !ok {
+			return math.MaxUint32
+		}
+		// Flip the bits so that the order of the keys is the same as the
+		// order of the numbers
+		key = math.Float32bits(float32(value))
+		if key&(1<<31) > 0 {
+			key = ^key
+		} else {
+			key |= 1 << 31
+		}
+	} else {
+		for idx := range 4 {
+			key <<= 8
+			if idx < len(str) {
+				key |= uint32(str[idx])
+			}
+		}
+	}
+	if c.reverse {
+		key = ^key
 	}
+	return key
+}
 
+// compare compares the whole values of the fields of the two items whose
+// keys from rank are equal
+func (c fieldCriterion) compare(item1 *Item, item2 *Item) int {
+	str1, str2 := c.value(item1), c.value(item2)
+	result := 0
+	if c.numeric {
+		value1, ok1 := numericValue(str1)
+		value2, ok2 := numericValue(str2)
+		if ok1 && ok2 {
+			result = cmp.Compare(value1, value2)
+		}
+	} else {
+		result = strings.Compare(str1, str2)
+	}
+	if c.reverse {
+		return -result
+	}
 	return result
 }
 
+// compareRanksWithFields is the slow path of compareRanks for the field
+// tiebreaks. When the keys of a field tie, the whole values of the field
+// are compared before moving on to the next criterion.
+func compareRanksWithFields(irank Result, jrank Result, tac bool) bool {
+	slot := len(irank.points) - 1
+	fieldIdx := 0
+	for _, criterion := range sortCriteria {
+		left, right := irank.points[slot], jrank.points[slot]
+		if left != right {
+			return left < right
+		}
+		if criterion == byField {
+			// Field tiebreak takes two slots
+			slot--
+			left, right = irank.points[slot], jrank.points[slot]
+			if left != right {
+				return left < right
+			}
+			if result := sortFields[fieldIdx].compare(irank.item, jrank.item); result != 0 {
+				return result < 0
+			}
+			fieldIdx++
+		}
+		slot--
+	}
+	for ; slot >= 0; slot-- {
+		left, right := irank.points[slot], jrank.points[slot]
+		if left != right {
+			return left < right
+		}
+	}
+	return (irank.item.Index() <= jrank.item.Index()) != tac
+}
+
 // Sort criteria to use. Never changes once fzf is started.
 var sortCriteria []criterion
 
+// Specs of the field tiebreaks in sortCriteria and the delimiter to split
+// the items. Never change once fzf is started.
+var sortFields []fieldCriterion
+var sortDelimiter Delimiter
+
 // Index returns ordinal index of the Item
 func (result *Result) Index() int32 {
 	return result.item.Index()
@@ -353,7 +482,9 @@ func (a ByRelevanceTac) Less(i, j int) bool {
 // For tac mode, runs of equal keys are reversed after sorting.
 func radixSortResults(a []Result, tac bool, scratch []Result) []Result {
 	n := len(a)
-	if n < 128 {
+	// The keys of the field tiebreaks are not exact, so they need the
+	// comparison sort
+	if n < 128 || len(sortFields) > 0 {
 		if tac {
 			sort.Sort(ByRelevanceTac(a))
 		} else {
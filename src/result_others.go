@@ -3,6 +3,9 @@
 package fzf
 
 func compareRanks(irank Result, jrank Result, tac bool) bool {
+	if len(sortFields) > 0 {
+		return compareRanksWithFields(irank, jrank, tac)
+	}
 	for idx := 3; idx >= 0; idx-- {
 		left := irank.points[idx]
 		right := jrank.points[idx]
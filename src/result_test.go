@@ -1,10 +1,12 @@
 package fzf
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 	"slices"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/junegunn/fzf/src/tui"
@@ -117,6 +119,96 @@ func TestChunkTiebreak(t *testing.T) {
 	test("hello foobar goodbye", Offset{5, 7}, "hello foobar") // TBD
 }
 
+func TestFieldTiebreak(t *testing.T) {
+	// FIXME global
+	sortCriteria = []criterion{byScore, byField, byLength}
+	sortDelimiter = Delimiter{}
+
+	order := func(spec fieldCriterion, inputs ...string) []string {
+		sortFields = []fieldCriterion{spec}
+		results := []Result{}
+		for idx, input := range inputs {
+			item := withIndex(&Item{text: util.RunesToChars([]rune(input))}, idx)
+			results = append(results, buildResult(item, []Offset{{0, 1}}, 100))
+		}
+		sort.Sort(ByRelevance(results))
+		sorted := []string{}
+		for _, result := range results {
+			sorted = append(sorted, result.item.text.ToString())
+		}
+		return sorted
+	}
+	check := func(sorted []string, expected ...string) {
+		if !slices.Equal(sorted, expected) {
+			t.Errorf("Expected %v, got %v", expected, sorted)
+		}
+	}
+
+	inputs := []string{"a 10 x", "b 9", "c -2.5 y", "d foo", "e 1K", "f 2026-01-01"}
+	check(order(fieldCriterion{field: Range{2, 2}, numeric: true}, inputs...),
+		"c -2.5 y", "b 9", "a 10 x", "e 1K", "f 2026-01-01", "d foo")
+	check(order(fieldCriterion{field: Range{2, 2}, numeric: true, reverse: true}, inputs...),
+		"f 2026-01-01", "e 1K", "a 10 x", "b 9", "c -2.5 y", "d foo")
+	check(order(fieldCriterion{field: Range{2, 2}}, inputs...),
+		"c -2.5 y", "a 10 x", "e 1K", "f 2026-01-01", "b 9", "d foo")
+
+	// Ties are broken by the next criterion, and missing field comes last in
+	// reverse order
+	check(order(fieldCriterion{field: Range{-1, -1}}, "aa x", "b", "a x", "c y"),
+		"b", "a x", "aa x", "c y")
+	check(order(fieldCriterion{field: Range{3, 3}, reverse: true}, "a b c", "a b", "a b d"),
+		"a b d", "a b c", "a b")
+
+	// Whole values are compared when the keys tie
+	timestamps := []string{"3 2026-03-01T10:00", "1 2026-01-15T09:00", "2 2026-01-15T08:30", "4 2026-12-31"}
+	check(order(fieldCriterion{field: Range{2, 2}}, timestamps...),
+		"2 2026-01-15T08:30", "1 2026-01-15T09:00", "3 2026-03-01T10:00", "4 2026-12-31")
+	check(order(fieldCriterion{field: Range{2, 2}, numeric: true, reverse: true}, timestamps...),
+		"4 2026-12-31", "3 2026-03-01T10:00", "1 2026-01-15T09:00", "2 2026-01-15T08:30")
+	check(order(fieldCriterion{field: Range{2, 2}, numeric: true}, "a 16777217", "b 16777216"),
+		"b 16777216", "a 16777217")
+
+	// Ties are broken before the next criterion
+	check(order(fieldCriterion{field: Range{2, 2}}, "a 2026-02-01", "bb 2026-01-01"),
+		"bb 2026-01-01", "a 2026-02-01")
+
+	// Radix sort is not used with the field tiebreak
+	results := []Result{}
+	for idx := range 200 {
+		item := withIndex(&Item{text: util.RunesToChars([]rune(fmt.Sprintf("x 2026-01-01T00:%03d", 199-idx)))}, idx)
+		results = append(results, buildResult(item, []Offset{{0, 1}}, 100))
+	}
+	radixSortResults(results, false, nil)
+	for idx, result := range results {
+		if expected := fmt.Sprintf("x 2026-01-01T00:%03d", idx); result.item.text.ToString() != expected {
+			t.Fatalf("Expected %s, got %s", expected, result.item.text.ToString())
+		}
+	}
+	sortFields = nil
+}
+
+func TestSourceTiebreak(t *testing.T) {
+	// FIXME global
+	sortCriteria = []criterion{byScore, bySource, byLength}
+
+	results := []Result{}
+	for idx, source := range []uint8{0, 2, 1, 2, 1} {
+		item := withIndex(&Item{text: util.RunesToChars([]rune(strings.Repeat("x", 5-idx)))}, idx)
+		item.text.SetSource(source)
+		results = append(results, buildResult(item, []Offset{{0, 1}}, 100))
+	}
+	sort.Sort(ByRelevance(results))
+	// In the order of the sources, then by length. Items without a source
+	// come last.
+	sorted := []int32{}
+	for _, result := range results {
+		sorted = append(sorted, result.Index())
+	}
+	if expected := []int32{4, 2, 3, 1, 0}; !slices.Equal(sorted, expected) {
+		t.Errorf("Expected %v, got %v", expected, sorted)
+	}
+}
+
 func TestColorOffset(t *testing.T) {
 	// ------------ 20 ----  --  ----
 	//   ++++++++        ++++++++++
@@ -5,6 +5,9 @@ package fzf
 import "unsafe"
 
 func compareRanks(irank Result, jrank Result, tac bool) bool {
+	if len(sortFields) > 0 {
+		return compareRanksWithFields(irank, jrank, tac)
+	}
 	left := *(*uint64)(unsafe.Pointer(&irank.points[0]))
 	right := *(*uint64)(unsafe.Pointer(&jrank.points[0]))
 	if left < right {
@@ -2,45 +2,178 @@ package fzf
 
 import (
 	"bufio"
-	"bytes"
 	"crypto/subtle"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var getRegex *regexp.Regexp
+var postRegex *regexp.Regexp
+var eventsRegex *regexp.Regexp
+var itemsRegex *regexp.Regexp
 
 func init() {
-	getRegex = regexp.MustCompile(`^GET /(?:\?([a-z0-9=&]+))? HTTP`)
+	getRegex = regexp.MustCompile(`^GET /(?:\?([a-zA-Z0-9=&,-]+))? HTTP`)
+	postRegex = regexp.MustCompile(`^POST /(?:\?([a-zA-Z0-9=&,-]+))? HTTP`)
+	eventsRegex = regexp.MustCompile(`^GET /events HTTP`)
+	itemsRegex = regexp.MustCompile(`^POST /items(?:\?([a-zA-Z0-9=&,-]+))? HTTP`)
 }
 
-type getParams struct {
+type pageParams struct {
 	limit  int
 	offset int
 }
 
+type getParams struct {
+	matches  pageParams
+	selected pageParams
+	fields   map[string]bool // Lowercase names of the fields to include; nil for all
+	order    []string        // Lowercase names of the fields in the requested order
+}
+
+// Include returns true if the field should be included in the response.
+// The explanation is costly to compute, so it's only included on request.
+func (params getParams) Include(field string) bool {
+	field = strings.ToLower(field)
+	if params.fields == nil {
+		return field != "explain"
+	}
+	return params.fields[field]
+}
+
 const (
-	crlf             = "\r\n"
-	httpOk           = "HTTP/1.1 200 OK" + crlf
-	httpBadRequest   = "HTTP/1.1 400 Bad Request" + crlf
-	httpUnauthorized = "HTTP/1.1 401 Unauthorized" + crlf
-	httpUnavailable  = "HTTP/1.1 503 Service Unavailable" + crlf
-	httpReadTimeout  = 10 * time.Second
-	channelTimeout   = 2 * time.Second
-	jsonContentType  = "Content-Type: application/json" + crlf
-	maxContentLength = 1024 * 1024
+	crlf              = "\r\n"
+	httpOk            = "HTTP/1.1 200 OK" + crlf
+	httpBadRequest    = "HTTP/1.1 400 Bad Request" + crlf
+	httpUnauthorized  = "HTTP/1.1 401 Unauthorized" + crlf
+	httpForbidden     = "HTTP/1.1 403 Forbidden" + crlf
+	httpUnavailable   = "HTTP/1.1 503 Service Unavailable" + crlf
+	httpReadTimeout   = 10 * time.Second
+	channelTimeout    = 2 * time.Second
+	settleTimeout     = 10 * time.Second
+	jsonContentType   = "Content-Type: application/json" + crlf
+	ndjsonType        = "Content-Type: application/x-ndjson" + crlf
+	maxContentLength  = 1024 * 1024
+	eventBufferSize   = 100
+	eventWriteTimeout = 2 * time.Second
 )
 
+// jsonAction is an element of the JSON array accepted by POST / as an
+// alternative to the action list syntax
+//
+//	[{"action":"change-query","arg":"foo"},{"action":"first"}]
+type jsonAction struct {
+	Action string  `json:"action"`
+	Arg    *string `json:"arg"`
+}
+
+type jsonActionError struct {
+	Error string `json:"error"`
+	Index *int   `json:"index,omitempty"`
+}
+
+// serverRequest is a list of actions received by the server. If done is not
+// nil, the terminal closes it once the actions are performed and the search
+// they trigger is complete.
+type serverRequest struct {
+	actions []*action
+	done    chan struct{}
+}
+
+// itemsRequest is a list of items received by the server (POST /items).
+// The reader closes done once the items are added to the list.
+type itemsRequest struct {
+	data     []byte
+	delimNil bool
+	done     chan struct{}
+}
+
 type httpServer struct {
-	apiKey        []byte
-	actionChannel chan []*action
-	getHandler    func(getParams) string
+	apiKey         []byte
+	readOnlyKey    []byte
+	allowedActions map[actionType]bool // nil to allow all actions
+	allowItems     bool                // POST /items when allowedActions is given
+	actionChannel  chan serverRequest
+	itemsChannel   chan itemsRequest
+	readZero       bool
+	getHandler     func(getParams) string
+	eventStream    *eventStream
+}
+
+// eventStream delivers newline-delimited JSON events to the clients
+// connected to GET /events
+type eventStream struct {
+	mutex       sync.Mutex
+	subscribers map[chan string]struct{}
+	closed      bool
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{subscribers: make(map[chan string]struct{})}
+}
+
+// Active returns true if there is at least one subscriber. Used to avoid
+// building event payloads nobody is going to read.
+func (s *eventStream) Active() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.subscribers) > 0
+}
+
+func (s *eventStream) subscribe() chan string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ch := make(chan string, eventBufferSize)
+	if s.closed {
+		close(ch)
+	} else {
+		s.subscribers[ch] = struct{}{}
+	}
+	return ch
+}
+
+func (s *eventStream) unsubscribe(ch chan string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, prs := s.subscribers[ch]; prs {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Publish sends the event to every subscriber without blocking. A subscriber
+// that cannot keep up is disconnected instead of silently missing events.
+func (s *eventStream) Publish(event string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close disconnects all subscribers
+func (s *eventStream) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = make(map[chan string]struct{})
+	s.closed = true
 }
 
 type listenAddress struct {
@@ -78,13 +211,45 @@ func parseListenAddress(address string) (listenAddress, error) {
 	return listenAddress{parts[0], port, ""}, nil
 }
 
-func startHttpServer(address listenAddress, actionChannel chan []*action, getHandler func(getParams) string) (net.Listener, int, error) {
+// parseListenActions parses the comma-separated list of actions allowed via
+// POST requests. "items" is not an action, but allows POST /items.
+func parseListenActions(str string) (map[actionType]bool, bool, error) {
+	allowed := make(map[actionType]bool)
+	items := false
+	for _, name := range strings.Split(strings.ToLower(str), ",") {
+		name = strings.TrimSpace(name)
+		if name == "items" {
+			items = true
+			continue
+		}
+		if len(name) == 0 || name != actionNameRegexp.FindString(name) {
+			return nil, false, errors.New("invalid action name: " + name)
+		}
+		if t := isExecuteAction(name + "()"); t != actIgnore {
+			allowed[t] = true
+			continue
+		}
+		actions, err := parseSingleActionList(name, false)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, action := range actions {
+			allowed[action.t] = true
+		}
+	}
+	return allowed, items, nil
+}
+
+func startHttpServer(address listenAddress, server httpServer) (net.Listener, int, error) {
 	host := address.host
 	port := address.port
 	apiKey := os.Getenv("FZF_API_KEY")
-	if !address.IsLocal() && len(apiKey) == 0 {
-		return nil, port, errors.New("FZF_API_KEY is required to allow remote access")
+	readOnlyKey := os.Getenv("FZF_API_KEY_READONLY")
+	if !address.IsLocal() && len(apiKey) == 0 && len(readOnlyKey) == 0 {
+		return nil, port, errors.New("FZF_API_KEY or FZF_API_KEY_READONLY is required to allow remote access")
 	}
+	server.apiKey = []byte(apiKey)
+	server.readOnlyKey = []byte(readOnlyKey)
 
 	var listener net.Listener
 	var err error
@@ -123,11 +288,6 @@ func startHttpServer(address listenAddress, actionChannel chan []*action, getHan
 	}
 
 	go func() {
-		server := httpServer{
-			apiKey:        []byte(apiKey),
-			actionChannel: actionChannel,
-			getHandler:    getHandler,
-		}
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
@@ -136,94 +296,167 @@ func startHttpServer(address listenAddress, actionChannel chan []*action, getHan
 				}
 				continue
 			}
-			conn.Write([]byte(server.handleHttpRequest(conn)))
-			conn.Close()
+			go server.serve(conn)
 		}
 	}()
 
 	return listener, port, nil
 }
 
+// serve handles the requests on the connection one after another until the
+// client closes it or asks to close it. Pipelined requests are read from the
+// same buffered reader, so they are answered in order.
+func (server *httpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	// Lines longer than the buffer are rejected, as with bufio.Scanner
+	reader := bufio.NewReaderSize(conn, bufio.MaxScanTokenSize)
+	peerErr := checkPeerCredentials(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(httpReadTimeout))
+		response, keepAlive := server.handleHttpRequest(conn, reader, peerErr)
+		if len(response) == 0 {
+			return
+		}
+		if _, err := conn.Write([]byte(response)); err != nil || !keepAlive {
+			return
+		}
+	}
+}
+
 // Here we are writing a simplistic HTTP server without using net/http
 // package to reduce the size of the binary.
 //
 // * No --listen:            2.8MB
 // * --listen with net/http: 5.7MB
 // * --listen w/o net/http:  3.3MB
-func (server *httpServer) handleHttpRequest(conn net.Conn) string {
+//
+// Returns the response and whether the connection should be kept open for
+// the next request. An empty response means that the connection is closed
+// by the client or taken over by the event stream. If peerErr is not nil,
+// the request is rejected as unauthorized.
+func (server *httpServer) handleHttpRequest(conn net.Conn, reader *bufio.Reader, peerErr error) (string, bool) {
 	contentLength := 0
+	contentType := ""
 	apiKey := ""
-	var bodyBuilder strings.Builder
-	answer := func(code string, message string) string {
-		message += "\n"
-		return code + fmt.Sprintf("Content-Length: %d%s", len(message), crlf+crlf+message)
+	keepAlive := true
+	answer := func(code string, message string) (string, bool) {
+		if len(message) > 0 {
+			message += "\n"
+		}
+		header := code + fmt.Sprintf("Content-Length: %d", len(message)) + crlf
+		if !keepAlive {
+			header += "Connection: close" + crlf
+		}
+		return header + crlf + message, keepAlive
 	}
-	unauthorized := func(message string) string {
+	unauthorized := func(message string) (string, bool) {
+		keepAlive = false
 		return answer(httpUnauthorized, message)
 	}
-	bad := func(message string) string {
+	bad := func(message string) (string, bool) {
+		// We can't tell where the next request begins
+		keepAlive = false
 		return answer(httpBadRequest, message)
 	}
-	good := func(message string) string {
+	good := func(message string) (string, bool) {
 		return answer(httpOk+jsonContentType, message)
 	}
-	conn.SetReadDeadline(time.Now().Add(httpReadTimeout))
-	scanner := bufio.NewScanner(conn)
-	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
-		found := bytes.Index(data, []byte(crlf))
-		if found >= 0 {
-			token := data[:found+len(crlf)]
-			return len(token), token, nil
-		}
-		if atEOF || bodyBuilder.Len()+len(data) >= contentLength {
-			return 0, data, bufio.ErrFinalToken
-		}
-		return 0, nil, nil
-	})
-
-	section := 0
-	var getMatch []string
-Loop:
-	for scanner.Scan() {
-		text := scanner.Text()
-		switch section {
-		case 0: // Request line
-			getMatch = getRegex.FindStringSubmatch(text)
-			if len(getMatch) == 0 && !strings.HasPrefix(text, "POST / HTTP") {
-				return bad("invalid request method")
-			}
-			section++
-		case 1: // Request headers
-			if text == crlf { // End of headers
-				if len(getMatch) > 0 {
-					break Loop
-				}
-				if contentLength == 0 {
-					return bad("content-length header missing")
+	readLine := func() (string, error) {
+		// ReadSlice fails with bufio.ErrBufferFull if the line is too long
+		line, err := reader.ReadSlice('\n')
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(line), crlf), nil
+	}
+
+	// Request line
+	if _, err := reader.Peek(1); err != nil {
+		// Connection closed or idle timeout
+		return "", false
+	}
+	text, err := readLine()
+	if err != nil {
+		return bad("invalid request")
+	}
+	getMatch := getRegex.FindStringSubmatch(text)
+	postMatch := postRegex.FindStringSubmatch(text)
+	events := eventsRegex.MatchString(text)
+	itemsMatch := itemsRegex.FindStringSubmatch(text)
+	if len(getMatch) == 0 && len(postMatch) == 0 && !events && len(itemsMatch) == 0 {
+		return bad("invalid request method")
+	}
+	if strings.HasSuffix(text, "HTTP/1.0") {
+		keepAlive = false
+	}
+
+	// Request headers
+	for {
+		text, err := readLine()
+		if err != nil {
+			return bad("invalid request headers")
+		}
+		if len(text) == 0 { // End of headers
+			break
+		}
+		pair := strings.SplitN(text, ":", 2)
+		if len(pair) == 2 {
+			value := strings.TrimSpace(pair[1])
+			switch strings.ToLower(pair[0]) {
+			case "connection":
+				switch strings.ToLower(value) {
+				case "close":
+					keepAlive = false
+				case "keep-alive":
+					keepAlive = true
 				}
-				section++
-				continue
-			}
-			pair := strings.SplitN(text, ":", 2)
-			if len(pair) == 2 {
-				switch strings.ToLower(pair[0]) {
-				case "content-length":
-					length, err := strconv.Atoi(strings.TrimSpace(pair[1]))
-					if err != nil || length <= 0 || length > maxContentLength {
-						return bad("invalid content length")
-					}
-					contentLength = length
-				case "x-api-key":
-					apiKey = strings.TrimSpace(pair[1])
+			case "content-length":
+				length, err := strconv.Atoi(value)
+				if err != nil || length <= 0 || length > maxContentLength {
+					return bad("invalid content length")
 				}
+				contentLength = length
+			case "content-type":
+				contentType = strings.ToLower(value)
+			case "x-api-key":
+				apiKey = value
 			}
-		case 2: // Request body
-			bodyBuilder.WriteString(text)
 		}
 	}
 
-	if len(server.apiKey) != 0 && subtle.ConstantTimeCompare([]byte(apiKey), server.apiKey) != 1 {
-		return unauthorized("invalid api key")
+	// Request body. It should be consumed even if it's not used so that the
+	// next request on the connection can be read.
+	if (len(postMatch) > 0 || len(itemsMatch) > 0) && contentLength == 0 {
+		return bad("content-length header missing")
+	}
+	var body string
+	if contentLength > 0 {
+		buf := make([]byte, contentLength)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return bad("incomplete request")
+		}
+		body = string(buf)
+	}
+
+	if peerErr != nil {
+		return unauthorized(peerErr.Error())
+	}
+
+	readOnly := false
+	if len(server.apiKey) != 0 || len(server.readOnlyKey) != 0 {
+		control := len(server.apiKey) != 0 && subtle.ConstantTimeCompare([]byte(apiKey), server.apiKey) == 1
+		readOnly = !control && len(server.readOnlyKey) != 0 && subtle.ConstantTimeCompare([]byte(apiKey), server.readOnlyKey) == 1
+		if !control && !readOnly {
+			return unauthorized("invalid api key")
+		}
+	}
+	if readOnly && (len(postMatch) > 0 || len(itemsMatch) > 0) {
+		return answer(httpForbidden, "read-only api key")
+	}
+
+	if events {
+		server.streamEvents(conn, reader)
+		return "", false
 	}
 
 	if len(getMatch) > 0 {
@@ -234,42 +467,205 @@ Loop:
 		return answer(httpUnavailable+jsonContentType, `{"error":"timeout"}`)
 	}
 
-	body := bodyBuilder.String()
-	if len(body) < contentLength {
-		return bad("incomplete request")
+	if len(itemsMatch) > 0 {
+		if server.allowedActions != nil && !server.allowItems {
+			return answer(httpForbidden, "items not allowed")
+		}
+		request := itemsRequest{
+			data:     []byte(body),
+			delimNil: parseItemsParams(itemsMatch[1], server.readZero),
+			done:     make(chan struct{})}
+		select {
+		case server.itemsChannel <- request:
+		case <-time.After(channelTimeout):
+			return answer(httpUnavailable, "")
+		}
+		<-request.done
+		return answer(httpOk, "")
 	}
-	body = body[:contentLength]
 
-	actions, err := parseSingleActionList(strings.Trim(string(body), "\r\n"), false)
-	if err != nil {
-		return bad(err.Error())
+	var actions []*action
+	if strings.HasPrefix(contentType, "application/json") {
+		var err *jsonActionError
+		actions, err = parseJsonActionList(body)
+		if err != nil {
+			bytes, _ := json.Marshal(err)
+			return answer(httpBadRequest+jsonContentType, string(bytes))
+		}
+	} else {
+		var err error
+		actions, err = parseSingleActionList(strings.Trim(body, "\r\n"), false)
+		if err != nil {
+			return answer(httpBadRequest, err.Error())
+		}
 	}
 	if len(actions) == 0 {
-		return bad("no action specified")
+		return answer(httpBadRequest, "no action specified")
+	}
+	if server.allowedActions != nil {
+		for _, action := range actions {
+			if !server.allowedActions[action.t] {
+				return answer(httpForbidden, "action not allowed: "+action.t.Name())
+			}
+		}
 	}
 
+	wait, params := parsePostParams(postMatch[1])
+	request := serverRequest{actions: actions}
+	if wait {
+		request.done = make(chan struct{})
+	}
 	select {
-	case server.actionChannel <- actions:
+	case server.actionChannel <- request:
 	case <-time.After(channelTimeout):
-		return httpUnavailable + crlf
+		return answer(httpUnavailable, "")
+	}
+	if !wait {
+		return answer(httpOk, "")
+	}
+
+	select {
+	case <-request.done:
+	case <-time.After(settleTimeout):
+		return answer(httpUnavailable+jsonContentType, `{"error":"timeout"}`)
+	}
+	response := server.getHandler(params)
+	if len(response) > 0 {
+		return good(response)
+	}
+	return answer(httpUnavailable+jsonContentType, `{"error":"timeout"}`)
+}
+
+// parseJsonActionList converts a JSON array of action objects into a list of
+// actions. Each argument is taken verbatim, so it doesn't have to be escaped
+// using the delimiters of the action list syntax.
+func parseJsonActionList(body string) ([]*action, *jsonActionError) {
+	var list []jsonAction
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		return nil, &jsonActionError{Error: "invalid json: " + err.Error()}
+	}
+	actions := []*action{}
+	for i, elem := range list {
+		index := i
+		fail := func(message string) ([]*action, *jsonActionError) {
+			return nil, &jsonActionError{Error: message, Index: &index}
+		}
+		name := strings.ToLower(elem.Action)
+		if name != actionNameRegexp.FindString(name) {
+			return fail("invalid action name: " + elem.Action)
+		}
+		takesArg := isExecuteAction(name+"()") != actIgnore
+		if elem.Arg == nil {
+			parsed, err := parseSingleActionList(name, false)
+			if err != nil {
+				if takesArg {
+					return fail("argument required: " + name)
+				}
+				return fail(err.Error())
+			}
+			actions = append(actions, parsed...)
+			continue
+		}
+		if !takesArg {
+			if _, err := parseSingleActionList(name, false); err != nil {
+				return fail(err.Error())
+			}
+			return fail("action does not take an argument: " + name)
+		}
+		parsed, err := parseSingleActionList(name+":"+*elem.Arg, false)
+		if err != nil {
+			return fail(err.Error())
+		}
+		actions = append(actions, parsed...)
+	}
+	return actions, nil
+}
+
+// streamEvents keeps the connection open and writes each published event as
+// a line of JSON until the client disconnects or fzf exits
+func (server *httpServer) streamEvents(conn net.Conn, reader *bufio.Reader) {
+	ch := server.eventStream.subscribe()
+
+	// Detect client disconnection. Read through the buffered reader of the
+	// connection so that the bytes the client has already sent are consumed
+	// in order.
+	conn.SetReadDeadline(time.Time{})
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := reader.Read(buf); err != nil {
+				server.eventStream.unsubscribe(ch)
+				return
+			}
+		}
+	}()
+
+	conn.Write([]byte(httpOk + ndjsonType + "Cache-Control: no-cache" + crlf + "Connection: close" + crlf + crlf))
+	for event := range ch {
+		conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		if _, err := conn.Write([]byte(event + "\n")); err != nil {
+			server.eventStream.unsubscribe(ch)
+			return
+		}
+	}
+}
+
+// parsePostParams returns whether the client wants to wait for the actions
+// to settle, and the parameters for the state returned in that case
+func parsePostParams(query string) (bool, getParams) {
+	wait := false
+	for _, pair := range strings.Split(query, "&") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 && parts[0] == "wait" {
+			wait = parts[1] != "0" && parts[1] != "false"
+		}
+	}
+	return wait, parseGetParams(query)
+}
+
+// parseItemsParams returns whether the items of POST /items are delimited by
+// NUL characters instead of newlines. Defaults to the --read0 option.
+func parseItemsParams(query string, readZero bool) bool {
+	for _, pair := range strings.Split(query, "&") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 && parts[0] == "read0" {
+			readZero = parts[1] != "0" && parts[1] != "false"
+		}
 	}
-	return httpOk + crlf
+	return readZero
 }
 
 func parseGetParams(query string) getParams {
-	params := getParams{limit: 100, offset: 0}
+	values := make(map[string]string)
 	for _, pair := range strings.Split(query, "&") {
 		parts := strings.SplitN(pair, "=", 2)
 		if len(parts) == 2 {
-			switch parts[0] {
-			case "limit", "offset":
-				if val, err := strconv.Atoi(parts[1]); err == nil {
-					if parts[0] == "limit" {
-						params.limit = val
-					} else {
-						params.offset = val
-					}
-				}
+			values[parts[0]] = parts[1]
+		}
+	}
+	setInt := func(key string, target *int) {
+		if val, err := strconv.Atoi(values[key]); err == nil {
+			*target = max(0, val)
+		}
+	}
+
+	page := pageParams{limit: 100, offset: 0}
+	setInt("limit", &page.limit)
+	setInt("offset", &page.offset)
+
+	params := getParams{matches: page, selected: page}
+	setInt("matches-limit", &params.matches.limit)
+	setInt("matches-offset", &params.matches.offset)
+	setInt("selected-limit", &params.selected.limit)
+	setInt("selected-offset", &params.selected.offset)
+
+	if fields, prs := values["fields"]; prs {
+		params.fields = make(map[string]bool)
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.ToLower(field)
+			if !params.fields[field] {
+				params.fields[field] = true
+				params.order = append(params.order, field)
 			}
 		}
 	}
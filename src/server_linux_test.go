@@ -0,0 +1,43 @@
+//go:build linux
+
+package fzf
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPeerCredentials(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "fzf.sock")
+	listener, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			defer conn.Close()
+			conn.Read(make([]byte, 1))
+		}
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Connection from the same user is accepted
+	if err := checkPeerCredentials(conn); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// Non-Unix connections are not checked
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if err := checkPeerCredentials(server); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package fzf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	stream := newEventStream()
+	if stream.Active() {
+		t.Error("Expected no subscribers")
+	}
+
+	ch1 := stream.subscribe()
+	ch2 := stream.subscribe()
+	if !stream.Active() {
+		t.Error("Expected subscribers")
+	}
+
+	stream.Publish("foo")
+	if event := <-ch1; event != "foo" {
+		t.Errorf("Expected foo, got %s", event)
+	}
+	if event := <-ch2; event != "foo" {
+		t.Errorf("Expected foo, got %s", event)
+	}
+
+	// Slow subscriber is disconnected
+	for range eventBufferSize + 1 {
+		stream.Publish("bar")
+	}
+	count := 0
+	for range ch1 {
+		count++
+	}
+	if count != eventBufferSize {
+		t.Errorf("Expected %d events, got %d", eventBufferSize, count)
+	}
+
+	// Unsubscribing twice is harmless
+	stream.unsubscribe(ch1)
+	stream.unsubscribe(ch2)
+	stream.unsubscribe(ch2)
+	if stream.Active() {
+		t.Error("Expected no subscribers")
+	}
+
+	// Subscribing after close returns a closed channel
+	stream.Close()
+	if _, ok := <-stream.subscribe(); ok {
+		t.Error("Expected closed channel")
+	}
+}
+
+func TestParseJsonActionList(t *testing.T) {
+	actions, err := parseJsonActionList(`[
+		{"action": "change-query", "arg": "foo(bar)+baz)"},
+		{"action": "First"},
+		{"action": "reload", "arg": ""},
+		{"action": "toggle-down"}
+	]`)
+	if err != nil {
+		t.Fatal(err.Error)
+	}
+	expected := []action{
+		{t: actChangeQuery, a: "foo(bar)+baz)"},
+		{t: actFirst},
+		{t: actReload},
+		{t: actToggle},
+		{t: actDown},
+	}
+	if len(actions) != len(expected) {
+		t.Fatalf("Expected %d actions, got %d", len(expected), len(actions))
+	}
+	for i, a := range actions {
+		if *a != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], *a)
+		}
+	}
+
+	for body, message := range map[string]string{
+		`[{"action":"up"},{"action":"foo"}]`:          "unknown action: foo",
+		`[{"action":"up"},{"action":"foo(x)"}]`:       "invalid action name: foo(x)",
+		`[{"action":"up"},{"action":"reload"}]`:       "argument required: reload",
+		`[{"action":"up"},{"action":"up","arg":"x"}]`: "action does not take an argument: up",
+	} {
+		_, err := parseJsonActionList(body)
+		if err == nil || err.Error != message || err.Index == nil || *err.Index != 1 {
+			t.Errorf("Unexpected error for %s: %v", body, err)
+		}
+	}
+
+	if _, err := parseJsonActionList(`{"action":"up"}`); err == nil || err.Index != nil {
+		t.Errorf("Expected invalid json error: %v", err)
+	}
+}
+
+func TestParsePostParams(t *testing.T) {
+	for query, expected := range map[string]bool{
+		"":                false,
+		"wait=1":          true,
+		"wait=0":          false,
+		"limit=1&wait=1":  true,
+		"wait=false&x=1":  false,
+		"offset=2&wait=y": true,
+	} {
+		wait, _ := parsePostParams(query)
+		if wait != expected {
+			t.Errorf("Expected wait=%v for %q", expected, query)
+		}
+	}
+	if _, params := parsePostParams("wait=1&limit=3&offset=2"); params.matches.limit != 3 || params.matches.offset != 2 {
+		t.Errorf("Unexpected params: %v", params)
+	}
+}
+
+func TestParseItemsParams(t *testing.T) {
+	for _, readZero := range []bool{false, true} {
+		if parseItemsParams("", readZero) != readZero {
+			t.Errorf("Expected --read0 setting to be the default")
+		}
+	}
+	if !parseItemsParams("read0=1", false) || parseItemsParams("read0=0", true) || parseItemsParams("read0=false", true) {
+		t.Error("Expected read0 parameter to override the default")
+	}
+}
+
+func TestParseGetParams(t *testing.T) {
+	params := parseGetParams("")
+	if params.matches != (pageParams{100, 0}) || params.selected != (pageParams{100, 0}) {
+		t.Errorf("Unexpected default params: %v", params)
+	}
+	if !params.Include("matches") || !params.Include("totalCount") || params.Include("explain") {
+		t.Error("Expected all fields but explain to be included by default")
+	}
+
+	if params = parseGetParams("fields=explain"); !params.Include("explain") {
+		t.Error("Expected explain to be included on request")
+	}
+
+	params = parseGetParams("selected-offset=5&limit=10&offset=1&matches-limit=20&fields=current,totalCount")
+	if params.matches != (pageParams{20, 1}) || params.selected != (pageParams{10, 5}) {
+		t.Errorf("Unexpected page params: %v", params)
+	}
+	if !params.Include("current") || !params.Include("totalCount") || params.Include("matches") {
+		t.Errorf("Unexpected fields: %v", params.fields)
+	}
+
+	// Negative values are clamped to zero
+	params = parseGetParams("limit=-1&offset=-5&selected-offset=-2")
+	if params.matches != (pageParams{0, 0}) || params.selected != (pageParams{0, 0}) {
+		t.Errorf("Unexpected page params: %v", params)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	dump := Status{Query: "foo", TotalCount: 10}
+	fields := string(selectFields(&dump, parseGetParams("fields=query,totalcount,foo")))
+	if fields != `{"query":"foo","totalCount":10}` {
+		t.Errorf("Unexpected fields: %s", fields)
+	}
+
+	// In the requested order
+	fields = string(selectFields(&dump, parseGetParams("fields=totalCount,query,TOTALCOUNT")))
+	if fields != `{"totalCount":10,"query":"foo"}` {
+		t.Errorf("Unexpected fields: %s", fields)
+	}
+
+	// Unknown field names are ignored
+	if fields := string(selectFields(&dump, parseGetParams("fields=foo,bar"))); fields != "{}" {
+		t.Errorf("Unexpected fields: %s", fields)
+	}
+}
+
+func TestServeKeepAlive(t *testing.T) {
+	actionChannel := make(chan serverRequest, 10)
+	server := httpServer{
+		actionChannel: actionChannel,
+		getHandler: func(params getParams) string {
+			return fmt.Sprintf(`{"limit":%d}`, params.matches.limit)
+		},
+		eventStream: newEventStream(),
+	}
+	client, conn := net.Pipe()
+	go server.serve(conn)
+
+	// Pipelined requests on a single connection. The body of a GET request is
+	// ignored.
+	go client.Write([]byte("GET /?limit=1 HTTP/1.1\r\nContent-Length: 4\r\n\r\nbody" +
+		"POST / HTTP/1.1\r\nContent-Length: 2\r\n\r\nup" +
+		"GET /?limit=2 HTTP/1.1\r\nConnection: close\r\n\r\n"))
+	output, _ := io.ReadAll(client)
+	expected := "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 12\r\n\r\n{\"limit\":1}\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 12\r\nConnection: close\r\n\r\n{\"limit\":2}\n"
+	if string(output) != expected {
+		t.Errorf("Unexpected response: %q", output)
+	}
+	if request := <-actionChannel; len(request.actions) != 1 || request.actions[0].t != actUp {
+		t.Errorf("Unexpected request: %v", request)
+	}
+
+	// Connection is closed after a malformed request
+	client, conn = net.Pipe()
+	go server.serve(conn)
+	go client.Write([]byte("PUT / HTTP/1.1\r\n\r\nGET / HTTP/1.1\r\n\r\n"))
+	output, _ = io.ReadAll(client)
+	if !strings.HasPrefix(string(output), "HTTP/1.1 400 Bad Request\r\n") || !strings.Contains(string(output), "Connection: close\r\n") {
+		t.Errorf("Unexpected response: %q", output)
+	}
+
+	// Connection is closed after an unauthorized request
+	server.apiKey = []byte("secret")
+	client, conn = net.Pipe()
+	go server.serve(conn)
+	go client.Write([]byte("GET / HTTP/1.1\r\nX-Api-Key: foo\r\n\r\nGET / HTTP/1.1\r\n\r\n"))
+	output, _ = io.ReadAll(client)
+	if !strings.HasPrefix(string(output), "HTTP/1.1 401 Unauthorized\r\n") || strings.Count(string(output), "HTTP/1.1") != 1 {
+		t.Errorf("Unexpected response: %q", output)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	server := httpServer{eventStream: newEventStream()}
+	client, conn := net.Pipe()
+	go server.serve(conn)
+
+	// The bytes sent after the request are consumed through the buffered
+	// reader of the connection
+	go client.Write([]byte("GET /events HTTP/1.1\r\n\r\nGET / HTTP/1.1\r\n\r\n"))
+	reader := bufio.NewReader(client)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "\r\n" {
+			break
+		}
+	}
+	server.eventStream.Publish(`{"event":"focus"}`)
+	if line, _ := reader.ReadString('\n'); line != "{\"event\":\"focus\"}\n" {
+		t.Errorf("Unexpected event: %q", line)
+	}
+
+	// The subscriber is removed when the client disconnects
+	client.Close()
+	for range 100 {
+		if !server.eventStream.Active() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Subscriber should be removed")
+}
+
+func TestServeItemsAllowed(t *testing.T) {
+	itemsChannel := make(chan itemsRequest, 1)
+	server := httpServer{
+		allowedActions: map[actionType]bool{actUp: true},
+		itemsChannel:   itemsChannel,
+		eventStream:    newEventStream(),
+	}
+	post := func() string {
+		client, conn := net.Pipe()
+		go server.serve(conn)
+		go client.Write([]byte("POST /items HTTP/1.1\r\nContent-Length: 4\r\nConnection: close\r\n\r\nfoo\n"))
+		output, _ := io.ReadAll(client)
+		return string(output)
+	}
+
+	// Rejected unless allowed by --listen-actions
+	if output := post(); !strings.HasPrefix(output, "HTTP/1.1 403 Forbidden\r\n") {
+		t.Errorf("Unexpected response: %q", output)
+	}
+
+	server.allowItems = true
+	go func() {
+		request := <-itemsChannel
+		if string(request.data) != "foo\n" {
+			t.Errorf("Unexpected data: %q", request.data)
+		}
+		close(request.done)
+	}()
+	if output := post(); !strings.HasPrefix(output, "HTTP/1.1 200 OK\r\n") {
+		t.Errorf("Unexpected response: %q", output)
+	}
+}
+
+func TestParseListenActions(t *testing.T) {
+	allowed, items, err := parseListenActions("change-query, Up,toggle-down,reload")
+	if err != nil || items {
+		t.Fatal(items, err)
+	}
+	for _, action := range []actionType{actChangeQuery, actUp, actToggle, actDown, actReload} {
+		if !allowed[action] {
+			t.Errorf("Expected %s to be allowed", action.Name())
+		}
+	}
+	if len(allowed) != 5 {
+		t.Errorf("Unexpected actions: %v", allowed)
+	}
+
+	if allowed, items, err := parseListenActions("up,items"); err != nil || !items || len(allowed) != 1 {
+		t.Errorf("Unexpected result: %v, %v, %v", allowed, items, err)
+	}
+
+	for _, str := range []string{"", "up,", "foo", "up,reload(ls)"} {
+		if _, _, err := parseListenActions(str); err == nil {
+			t.Errorf("Expected error for %q", str)
+		}
+	}
+}
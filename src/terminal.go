@@ -77,7 +77,7 @@ const blockDuration = 1 * time.Second
 const maxCurrentItemEnvSize = 64 * 1024
 
 func init() {
-	placeholder = regexp.MustCompile(`\\?(?:{[+*sfr]*[0-9,-.]*}|{q(?::s?[0-9,-.]+)?}|{fzf:(?:query|action|prompt)}|{[+*]?f?nf?})`)
+	placeholder = regexp.MustCompile(`\\?(?:{[+*sfr]*[0-9,-.]*}|{q(?::s?[0-9,-.]+)?}|{fzf:(?:query|action|prompt|explain)}|{[+*]?f?nf?}|{[+*]?source})`)
 	whiteSuffix = regexp.MustCompile(`\s*$`)
 	offsetComponentRegex = regexp.MustCompile(`([+-][0-9]+)|(-?/[1-9][0-9]*)`)
 	offsetTrimCharsRegex = regexp.MustCompile(`[^0-9/+-]`)
@@ -157,6 +157,7 @@ type waitState struct {
 	blockedAt time.Time
 	pending   []*action
 	searching bool // a search is in progress or the input is still loading
+	settlers  []chan struct{}
 }
 
 type previewer struct {
@@ -236,9 +237,14 @@ const (
 )
 
 type StatusItem struct {
-	Index     int    `json:"index"`
-	Text      string `json:"text"`
-	Positions []int  `json:"positions,omitempty"`
+	Index     int      `json:"index"`
+	Text      string   `json:"text"`
+	Source    string   `json:"source,omitempty"`
+	Positions []int    `json:"positions,omitempty"`
+	Score     *int     `json:"score,omitempty"`
+	Offsets   []Offset `json:"offsets,omitempty"`
+	Nth       []string `json:"nth,omitempty"`
+	Selected  bool     `json:"selected,omitempty"`
 }
 
 type Status struct {
@@ -252,6 +258,20 @@ type Status struct {
 	Current    *StatusItem  `json:"current"`
 	Matches    []StatusItem `json:"matches"`
 	Selected   []StatusItem `json:"selected"`
+	Explain    *Explanation `json:"explain,omitempty"`
+}
+
+// StatusEvent is a summary of the program state written to GET /events
+// stream when an event occurs
+type StatusEvent struct {
+	Event         string      `json:"event"`
+	Reading       bool        `json:"reading"`
+	Query         string      `json:"query"`
+	Position      int         `json:"position"`
+	TotalCount    int         `json:"totalCount"`
+	MatchCount    int         `json:"matchCount"`
+	SelectedCount int         `json:"selectedCount"`
+	Current       *StatusItem `json:"current"`
 }
 
 type versionedCallback struct {
@@ -352,6 +372,7 @@ type Terminal struct {
 	printQueue           []string
 	printQuery           bool
 	history              *History
+	frecency             *Frecency
 	cycle                bool
 	highlightLine        bool
 	headerVisible        bool
@@ -381,6 +402,9 @@ type Terminal struct {
 	listenPort           *int
 	listener             net.Listener
 	listenUnsafe         bool
+	listenActions        map[actionType]bool
+	listenItems          bool
+	eventStream          *eventStream
 	borderShape          tui.BorderShape
 	listBorderShape      tui.BorderShape
 	inputBorderShape     tui.BorderShape
@@ -450,7 +474,8 @@ type Terminal struct {
 	startChan            chan fitpad
 	killChan             chan bool
 	killedChan           chan bool
-	serverInputChan      chan []*action
+	serverInputChan      chan serverRequest
+	itemsChan            chan itemsRequest
 	callbackChan         chan versionedCallback
 	bgQueue              map[action][]func(bool)
 	bgSemaphore          chan struct{}
@@ -1088,11 +1113,14 @@ func NewTerminal(opts *Options, eventBox *util.EventBox, executor *util.Executor
 		pressed:            "",
 		printQuery:         opts.PrintQuery,
 		history:            opts.History,
+		frecency:           opts.Frecency,
 		margin:             opts.Margin,
 		padding:            opts.Padding,
 		unicode:            opts.Unicode,
 		listenAddr:         opts.ListenAddr,
 		listenUnsafe:       opts.Unsafe,
+		listenActions:      opts.ListenActions,
+		listenItems:        opts.ListenItems,
 		borderShape:        opts.BorderShape,
 		listBorderShape:    opts.ListBorderShape,
 		inputBorderShape:   opts.InputBorderShape,
@@ -1172,7 +1200,8 @@ func NewTerminal(opts *Options, eventBox *util.EventBox, executor *util.Executor
 		startChan:          make(chan fitpad, 1),
 		killChan:           make(chan bool),
 		killedChan:         make(chan bool),
-		serverInputChan:    make(chan []*action, 100),
+		serverInputChan:    make(chan serverRequest, 100),
+		itemsChan:          make(chan itemsRequest),
 		callbackChan:       make(chan versionedCallback, maxBgProcesses),
 		bgQueue:            make(map[action][]func(bool)),
 		bgSemaphore:        make(chan struct{}, maxBgProcesses),
@@ -1377,7 +1406,16 @@ func NewTerminal(opts *Options, eventBox *util.EventBox, executor *util.Executor
 	_, t.hasLoadActions = t.keymap[tui.Load.AsEvent()]
 
 	if t.listenAddr != nil {
-		listener, port, err := startHttpServer(*t.listenAddr, t.serverInputChan, t.dumpStatus)
+		t.eventStream = newEventStream()
+		listener, port, err := startHttpServer(*t.listenAddr, httpServer{
+			allowedActions: t.listenActions,
+			allowItems:     t.listenItems,
+			actionChannel:  t.serverInputChan,
+			itemsChannel:   t.itemsChan,
+			readZero:       opts.ReadZero,
+			getHandler:     t.dumpStatus,
+			eventStream:    t.eventStream,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -1955,7 +1993,11 @@ func (t *Terminal) UpdateCount(cnt int, final bool, failedCommand *string) {
 	if t.hasLoadActions && t.reading && final {
 		t.triggerLoad = true
 	}
+	loaded := t.reading && final
 	t.reading = !final
+	if loaded {
+		t.publishEvent("load")
+	}
 	t.failed = failedCommand
 	suppressed := t.suppress
 	t.mutex.Unlock()
@@ -1992,6 +2034,18 @@ func (t *Terminal) changeFooter(footer string) bool {
 	return needFullRedraw
 }
 
+// DeselectItems removes the items from the selection
+func (t *Terminal) DeselectItems(indexes []int32) {
+	t.mutex.Lock()
+	for _, index := range indexes {
+		if _, found := t.selected[index]; found {
+			delete(t.selected, index)
+			t.version++
+		}
+	}
+	t.mutex.Unlock()
+}
+
 // UpdateHeader updates the header
 func (t *Terminal) UpdateHeader(header []Item) {
 	t.mutex.Lock()
@@ -2202,6 +2256,8 @@ func (t *Terminal) UpdateList(result MatchResult) {
 			}
 		}
 	}
+	t.publishEvent("result")
+	t.settle()
 	updateList := !t.trackBlocked && !t.pendingReqList
 	updatePrompt := (trackWasBlocked && !t.trackBlocked) || (waitWasBlocked && !t.wait.blocked)
 	t.mutex.Unlock()
@@ -2212,7 +2268,7 @@ func (t *Terminal) UpdateList(result MatchResult) {
 	// blocking on eventBox.Set while trying to drain the channel.
 	if wakeUp {
 		go func() {
-			t.serverInputChan <- []*action{{t: actIgnore}}
+			t.serverInputChan <- serverRequest{actions: []*action{{t: actIgnore}}}
 		}()
 	}
 
@@ -2246,19 +2302,28 @@ func (t *Terminal) output() bool {
 			return item.acceptNth(t.ansi, t.delimiter, t.acceptNth)
 		}
 	}
-	found := len(t.selected) > 0
-	if !found {
-		current := t.currentItem()
-		if current != nil {
-			t.printer(transform(current))
-			found = true
-		}
-	} else {
-		for _, sel := range t.sortSelected() {
-			t.printer(transform(sel.item))
+	items := t.acceptedItems()
+	for _, item := range items {
+		t.printer(transform(item))
+	}
+	return len(items) > 0
+}
+
+// acceptedItems returns the selected items, or the current item if nothing
+// is selected
+func (t *Terminal) acceptedItems() []*Item {
+	if len(t.selected) == 0 {
+		if current := t.currentItem(); current != nil {
+			return []*Item{current}
 		}
+		return nil
 	}
-	return found
+	sorted := t.sortSelected()
+	items := make([]*Item, len(sorted))
+	for idx, sel := range sorted {
+		items[idx] = sel.item
+	}
+	return items
 }
 
 func (t *Terminal) sortSelected() []selectedItem {
@@ -5450,6 +5515,14 @@ func parsePlaceholder(match string) (bool, string, placeholderFlags) {
 		return false, match, flags
 	}
 
+	if strings.HasSuffix(match, "source}") {
+		// {source}, {+source}, and {*source}. Not to be confused with the
+		// flags of the other placeholders.
+		flags.plus = match[1] == '+'
+		flags.asterisk = match[1] == '*'
+		return false, "{source}", flags
+	}
+
 	trimmed := ""
 	for _, char := range match[1:] {
 		switch char {
@@ -5502,6 +5575,7 @@ type replacePlaceholderParams struct {
 	allItems   [3][]*Item // current, select, and all matched items
 	lastAction actionType
 	prompt     string
+	explain    func() string
 	executor   *util.Executor
 }
 
@@ -5520,6 +5594,7 @@ func (t *Terminal) replacePlaceholder(template string, forcePlus bool, input str
 		allItems:   list,
 		lastAction: t.lastAction,
 		prompt:     t.promptString,
+		explain:    t.explainCurrent,
 		executor:   t.executor,
 	})
 }
@@ -5618,10 +5693,20 @@ func replacePlaceholder(params replacePlaceholderParams) (string, []string) {
 					return params.executor.QuoteEntry(item.AsString(params.stripAnsi))
 				}
 			}
+		case match == "{source}":
+			replace = func(item *Item) string {
+				return params.executor.QuoteEntry(item.SourceName())
+			}
 		case match == "{fzf:action}":
 			return params.lastAction.Name()
 		case match == "{fzf:prompt}":
 			return params.executor.QuoteEntry(params.prompt)
+		case match == "{fzf:explain}":
+			explanation := ""
+			if params.explain != nil {
+				explanation = params.explain()
+			}
+			return params.executor.QuoteEntry(explanation)
 		default:
 			// token type and also failover (below)
 			rangeExpressions := strings.Split(match[1:len(match)-1], ",")
@@ -5912,6 +5997,41 @@ func (t *Terminal) currentItem() *Item {
 	return nil
 }
 
+// explanation returns the breakdown of the score of the current item and the
+// sort criterion that decided its order against the next item (or the
+// previous one if it's the last)
+func (t *Terminal) explanation() *Explanation {
+	cnt := t.merger.Length()
+	pattern := t.resultMerger.pattern
+	if pattern == nil || t.cy < 0 || t.cy >= cnt {
+		return nil
+	}
+	current := t.merger.Get(t.cy)
+	explanation := pattern.Explain(current.item, t.slab)
+	if explanation == nil {
+		return nil
+	}
+	if t.merger.sorted && cnt > 1 {
+		neighbor := t.cy + 1
+		if neighbor >= cnt {
+			neighbor = t.cy - 1
+		}
+		explanation.Tiebreak = &TiebreakExplanation{
+			Position:  neighbor,
+			Criterion: explainTiebreak(current, t.merger.Get(neighbor))}
+	}
+	return explanation
+}
+
+// explainCurrent returns the explanation of the current item for
+// {fzf:explain}
+func (t *Terminal) explainCurrent() string {
+	if explanation := t.explanation(); explanation != nil {
+		return explanation.String()
+	}
+	return ""
+}
+
 func (t *Terminal) isCurrentItemMatch() bool {
 	cnt := t.merger.Length()
 	if t.cy >= 0 && cnt > 0 && cnt > t.cy {
@@ -6118,6 +6238,24 @@ func (t *Terminal) cancelWait() {
 	t.wait.pending = nil
 }
 
+// settle releases the HTTP clients waiting for their actions to take effect
+// (POST /?wait=1) unless a search is in progress or actions are pending
+func (t *Terminal) settle() {
+	if t.wait.searching || t.wait.blocked || len(t.wait.pending) > 0 {
+		return
+	}
+	t.releaseSettlers()
+}
+
+// releaseSettlers unconditionally releases the waiting HTTP clients. Called
+// when fzf is about to exit, so that they don't wait for the timeout.
+func (t *Terminal) releaseSettlers() {
+	for _, settler := range t.wait.settlers {
+		close(settler)
+	}
+	t.wait.settlers = nil
+}
+
 // Debounce visual feedback so quick searches don't cause flashing
 func (t *Terminal) waitFeedback() bool {
 	return t.wait.blocked && time.Since(t.wait.blockedAt) > progressMinDuration
@@ -6556,7 +6694,9 @@ func (t *Terminal) Loop() error {
 			}
 			if t.listener != nil {
 				t.listener.Close()
+				t.eventStream.Close()
 			}
+			t.releaseSettlers()
 			t.tui.Close()
 			code = getCode()
 			if code <= ExitNoMatch && t.history != nil {
@@ -6621,6 +6761,9 @@ func (t *Terminal) Loop() error {
 							info = true
 						}
 						focusChanged := focusedIndex != currentIndex
+						if focusChanged {
+							t.publishEvent("focus")
+						}
 						if (t.hasFocusActions || t.infoCommand != "") && focusChanged && currentIndex != t.lastFocus {
 							t.lastFocus = currentIndex
 							t.eventChan <- tui.Focus.AsEvent()
@@ -6698,6 +6841,9 @@ func (t *Terminal) Loop() error {
 					case reqClose:
 						exit(func() int {
 							if t.output() {
+								if t.frecency != nil {
+									t.frecency.record(t.acceptedItems())
+								}
 								return ExitOk
 							}
 							return ExitNoMatch
@@ -6852,6 +6998,7 @@ func (t *Terminal) Loop() error {
 		var event tui.Event
 		actions := []*action{}
 		callbacks := []versionedCallback{}
+		var settler chan struct{}
 		select {
 		case event = <-t.keyChan:
 			needBarrier = true
@@ -6873,8 +7020,12 @@ func (t *Terminal) Loop() error {
 					}
 				}
 			}
-		case serverActions := <-t.serverInputChan:
+		case request := <-t.serverInputChan:
 			event = tui.Invalid.AsEvent()
+			serverActions := request.actions
+			if request.done != nil {
+				settler = request.done
+			}
 			if t.listenAddr == nil || t.listenAddr.IsLocal() || t.listenUnsafe {
 				actions = serverActions
 			} else {
@@ -6908,6 +7059,9 @@ func (t *Terminal) Loop() error {
 		}
 
 		t.mutex.Lock()
+		if settler != nil {
+			t.wait.settlers = append(t.wait.settlers, settler)
+		}
 		// Ignore --expect keys while wait-blocked like the rest of the input
 		if !t.wait.blocked {
 			for key, ret := range t.expect {
@@ -7088,7 +7242,11 @@ func (t *Terminal) Loop() error {
 					if t.history != nil {
 						t.history.append(string(t.input))
 					}
+					if t.frecency != nil {
+						t.frecency.record(t.acceptedItems())
+					}
 
+					t.releaseSettlers()
 					if len(t.proxyScript) > 0 {
 						data := strings.Join(append([]string{command}, t.environ()...), "\x00")
 						os.WriteFile(t.proxyScript+becomeSuffix, []byte(data), 0600)
@@ -8548,6 +8706,12 @@ func (t *Terminal) Loop() error {
 			}
 			queryChanged = queryChanged || t.pasting == nil && string(previousInput) != string(t.input)
 			changed = changed || queryChanged
+			if queryChanged {
+				t.publishEvent("change")
+			}
+			if t.version != previousVersion {
+				t.publishEvent("multi")
+			}
 			if onChanges, prs := t.keymap[tui.Change.AsEvent()]; queryChanged && prs && !doActions(onChanges) {
 				continue
 			}
@@ -8602,6 +8766,7 @@ func (t *Terminal) Loop() error {
 			t.pendingReqList = false
 			req(reqList)
 		}
+		t.settle()
 
 		t.mutex.Unlock() // Must be unlocked before touching reqBox
 
@@ -8768,19 +8933,56 @@ func (t *Terminal) dumpItem(i *Item) StatusItem {
 		return StatusItem{}
 	}
 	item := StatusItem{
-		Index: int(i.Index()),
-		Text:  i.AsString(t.ansi),
+		Index:  int(i.Index()),
+		Text:   i.AsString(t.ansi),
+		Source: i.SourceName(),
 	}
 	if t.resultMerger.pattern != nil {
-		_, _, pos := t.resultMerger.pattern.MatchItem(i, true, t.slab)
+		offsets, score, pos, matched := t.resultMerger.pattern.MatchScore(i, true, t.slab)
 		if pos != nil {
 			sort.Ints(*pos)
 			item.Positions = *pos
 		}
+		if matched {
+			item.Score = &score
+			item.Offsets = offsets
+		}
+	}
+	if len(t.nthCurrent) > 0 {
+		tokens := Transform(Tokenize(i.text.ToString(), t.delimiter), t.nthCurrent)
+		item.Nth = make([]string, len(tokens))
+		for idx, token := range tokens {
+			item.Nth[idx] = StripLastDelimiter(token.text.ToString(), t.delimiter)
+		}
 	}
+	_, item.Selected = t.selected[i.Index()]
 	return item
 }
 
+// publishEvent writes a summary of the current state to the clients of
+// GET /events. t.mutex must be held by the caller.
+func (t *Terminal) publishEvent(event string) {
+	if t.eventStream == nil || !t.eventStream.Active() {
+		return
+	}
+	var current *StatusItem
+	if currentItem := t.currentItem(); currentItem != nil {
+		item := t.dumpItem(currentItem)
+		current = &item
+	}
+	bytes, _ := json.Marshal(&StatusEvent{
+		Event:         event,
+		Reading:       t.reading,
+		Query:         string(t.input),
+		Position:      t.cy,
+		TotalCount:    t.count,
+		MatchCount:    t.resultMerger.Length(),
+		SelectedCount: len(t.selected),
+		Current:       current,
+	})
+	t.eventStream.Publish(string(bytes))
+}
+
 func (t *Terminal) tryLock(timeout time.Duration) bool {
 	sleepDuration := 10 * time.Millisecond
 
@@ -8804,24 +9006,37 @@ func (t *Terminal) dumpStatus(params getParams) string {
 	}
 	defer t.mutex.Unlock()
 
-	selectedItems := t.sortSelected()
-	selected := make([]StatusItem, max(0, min(params.limit, len(selectedItems)-params.offset)))
-	for i := range selected {
-		selected[i] = t.dumpItem(selectedItems[i+params.offset].item)
+	var selected []StatusItem
+	if params.Include("selected") {
+		page := params.selected
+		selectedItems := t.sortSelected()
+		selected = make([]StatusItem, max(0, min(page.limit, len(selectedItems)-page.offset)))
+		for i := range selected {
+			selected[i] = t.dumpItem(selectedItems[i+page.offset].item)
+		}
 	}
 
-	matches := make([]StatusItem, max(0, min(params.limit, t.resultMerger.Length()-params.offset)))
-	for i := range matches {
-		matches[i] = t.dumpItem(t.resultMerger.Get(i + params.offset).item)
+	var matches []StatusItem
+	if params.Include("matches") {
+		page := params.matches
+		matches = make([]StatusItem, max(0, min(page.limit, t.resultMerger.Length()-page.offset)))
+		for i := range matches {
+			matches[i] = t.dumpItem(t.resultMerger.Get(i + page.offset).item)
+		}
 	}
 
 	var current *StatusItem
 	currentItem := t.currentItem()
-	if currentItem != nil {
+	if currentItem != nil && params.Include("current") {
 		item := t.dumpItem(currentItem)
 		current = &item
 	}
 
+	var explanation *Explanation
+	if params.Include("explain") {
+		explanation = t.explanation()
+	}
+
 	dump := Status{
 		Reading:    t.reading,
 		Progress:   t.progress,
@@ -8831,9 +9046,57 @@ func (t *Terminal) dumpStatus(params getParams) string {
 		TotalCount: t.count,
 		MatchCount: t.resultMerger.Length(),
 		Current:    current,
+		Explain:    explanation,
 		Matches:    matches,
 		Selected:   selected,
 	}
-	bytes, _ := json.Marshal(&dump) // TODO: Errors?
+	var bytes []byte
+	if params.fields != nil {
+		bytes = selectFields(&dump, params)
+	} else {
+		bytes, _ = json.Marshal(&dump) // TODO: Errors?
+	}
 	return string(bytes)
 }
+
+// selectFields returns the JSON object of the fields of the status selected
+// by the parameters in the requested order. Unknown field names are ignored.
+func selectFields(dump *Status, params getParams) []byte {
+	type field struct {
+		name  string
+		value any
+	}
+	fields := make(map[string]field)
+	for _, f := range []field{
+		{"reading", dump.Reading},
+		{"progress", dump.Progress},
+		{"query", dump.Query},
+		{"position", dump.Position},
+		{"sort", dump.Sort},
+		{"totalCount", dump.TotalCount},
+		{"matchCount", dump.MatchCount},
+		{"current", dump.Current},
+		{"matches", dump.Matches},
+		{"selected", dump.Selected},
+		{"explain", dump.Explain},
+	} {
+		fields[strings.ToLower(f.name)] = f
+	}
+
+	output := []byte{'{'}
+	for _, name := range params.order {
+		f, found := fields[name]
+		if !found {
+			continue
+		}
+		if len(output) > 1 {
+			output = append(output, ',')
+		}
+		key, _ := json.Marshal(f.name)
+		value, _ := json.Marshal(f.value)
+		output = append(output, key...)
+		output = append(output, ':')
+		output = append(output, value...)
+	}
+	return append(output, '}')
+}
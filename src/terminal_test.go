@@ -162,6 +162,16 @@ func TestReplacePlaceholder(t *testing.T) {
 	result = replacePlaceholderTest("echo {}/{1}/{3}/{2..3}", true, Delimiter{regex: regex}, printsep, false, "query", items1)
 	checkFormat("echo {{.O}}  foo{{.I}}bar baz{{.O}}/{{.O}}f{{.O}}/{{.O}}r b{{.O}}/{{.O}}{{.I}}bar b{{.O}}")
 
+	// {source}
+	setSourceNames([]string{"branch", "tag"})
+	defer setSourceNames(nil)
+	branch, tag, other := newItem("main"), newItem("v1"), newItem("HEAD")
+	branch.text.SetSource(1)
+	tag.text.SetSource(2)
+	result = replacePlaceholderTest("echo {source} {+source} {}", true, Delimiter{}, printsep, false, "query",
+		[3][]*Item{{branch}, {branch, tag, other}, nil})
+	checkFormat("echo {{.O}}branch{{.O}} {{.O}}branch{{.O}} {{.O}}tag{{.O}} {{.O}}{{.O}} {{.O}}main{{.O}}")
+
 	/*
 		Test single placeholders, but focus on the placeholders' parameters (e.g. flags).
 		see: TestParsePlaceholder
@@ -466,6 +476,9 @@ func TestParsePlaceholder(t *testing.T) {
 		`{+n}`:  `{+n}`,
 		`{f}`:   `{f}`,
 		`{+nf}`: `{+nf}`,
+		// input source
+		`{source}`:  `{source}`,
+		`{+source}`: `{+source}`,
 
 		// II. token type placeholders
 		`{..}`:     `{..}`,
@@ -19,6 +19,13 @@ const (
 	flagMayFold
 )
 
+// The upper bits of the flags hold the 1-based index of the input source of
+// the item (--source)
+const (
+	sourceShift = 2
+	MaxSources  = 1<<(8-sourceShift) - 1
+)
+
 type Chars struct {
 	slice []byte // or []rune
 	// Only ever set, never cleared, so a reader racing a Prepend sees either
@@ -180,6 +187,18 @@ func (chars *Chars) IsBytes() bool {
 	return chars.flags&flagInBytes != 0
 }
 
+// SetSource sets the 1-based index of the input source of the item. Must be
+// called before the item is visible to other goroutines.
+func (chars *Chars) SetSource(source uint8) {
+	chars.flags = chars.flags&(1<<sourceShift-1) | source<<sourceShift
+}
+
+// Source returns the 1-based index of the input source of the item, or 0 if
+// it's not from any of them
+func (chars *Chars) Source() uint8 {
+	return chars.flags >> sourceShift
+}
+
 // MayFoldToAscii reports whether the text holds a rune that case folding or
 // normalization could turn into an ASCII character. When false, an ASCII
 // pattern character can only match the identical ASCII rune, which is what
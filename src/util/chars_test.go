@@ -246,6 +246,29 @@ func TestMayFoldFlag(t *testing.T) {
 	}
 }
 
+func TestCharsSource(t *testing.T) {
+	chars := ToChars([]byte("café"))
+	if chars.Source() != 0 {
+		t.Errorf("expected no source, actual: %d", chars.Source())
+	}
+	chars.SetSource(MaxSources)
+	if chars.Source() != MaxSources {
+		t.Errorf("expected source %d, actual: %d", MaxSources, chars.Source())
+	}
+	chars.SetSource(3)
+	if chars.Source() != 3 {
+		t.Errorf("expected source 3, actual: %d", chars.Source())
+	}
+	// The other flags are kept
+	if chars.IsBytes() || !chars.MayFoldToAscii() {
+		t.Error("SetSource should not change the other flags")
+	}
+	chars.Prepend("é")
+	if chars.Source() != 3 {
+		t.Errorf("Prepend should not change the source, actual: %d", chars.Source())
+	}
+}
+
 // Runes and ToRunes alias the text in rune mode, so a consumer that mutates
 // what they return changes the text without updating the cached fold bit. This
 // verifies the aliasing so the read-only contract on those methods is not
@@ -0,0 +1,106 @@
+package fzf
+
+import (
+	"strings"
+	"sync"
+)
+
+// watchEvent is an entry created in, removed from, or modified in a watched
+// directory
+type watchEvent struct {
+	dir      string
+	name     string
+	depth    int // Depth of the entry from the walker root
+	isDir    bool
+	removed  bool
+	modified bool // Written or changed its attributes
+}
+
+// watchedItem is the item of a reported entry
+type watchedItem struct {
+	index int32
+	line  string
+}
+
+// walkerWatch keeps the walker results up to date with the changes in the
+// walked directories (--walker-watch)
+type walkerWatch struct {
+	watcher *dirWatcher
+	handler func([]watchEvent)
+	mutex   sync.Mutex
+	closed  bool
+	items   map[string]watchedItem // Items of the reported entries by path
+}
+
+func newWalkerWatch(watcher *dirWatcher) *walkerWatch {
+	return &walkerWatch{watcher: watcher, items: make(map[string]watchedItem)}
+}
+
+// push pushes the line of the entry unless it's already reported or the
+// watch is closed, and records the index of the item. The lock is held while
+// pushing so that nothing is added to the list after close returns.
+func (w *walkerWatch) push(path string, line string, pusher func([]byte) (int32, bool)) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if _, found := w.items[path]; found || w.closed {
+		return false
+	}
+	index, pushed := pusher(stringBytes(line))
+	if pushed {
+		w.items[path] = watchedItem{index, line}
+	}
+	return pushed
+}
+
+// line returns the line of the reported entry
+func (w *walkerWatch) line(path string) (string, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	item, found := w.items[path]
+	return item.line, found
+}
+
+// forget returns the indexes of the items of the removed entry and the
+// entries under it
+func (w *walkerWatch) forget(path string, isDir bool, sep string) []int32 {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	indexes := []int32{}
+	if item, found := w.items[path]; found {
+		indexes = append(indexes, item.index)
+		delete(w.items, path)
+	}
+	if isDir {
+		prefix := strings.TrimSuffix(path, sep) + sep
+		for entry, item := range w.items {
+			if strings.HasPrefix(entry, prefix) {
+				indexes = append(indexes, item.index)
+				delete(w.items, entry)
+			}
+		}
+	}
+	return indexes
+}
+
+func (w *walkerWatch) isClosed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.closed
+}
+
+// run handles the events until the watch is closed
+func (w *walkerWatch) run() {
+	w.watcher.watch(func(events []watchEvent) {
+		if !w.isClosed() {
+			w.handler(events)
+		}
+	})
+}
+
+// close stops watching the directories
+func (w *walkerWatch) close() {
+	w.mutex.Lock()
+	w.closed = true
+	w.mutex.Unlock()
+	w.watcher.close()
+}
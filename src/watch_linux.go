@@ -0,0 +1,116 @@
+//go:build linux
+
+package fzf
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const walkerWatchSupported = true
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_CLOSE_WRITE | unix.IN_ATTRIB
+
+type watchedDir struct {
+	path  string
+	depth int
+}
+
+// dirWatcher reports the entries created in, removed from, or modified in
+// the watched directories using inotify
+type dirWatcher struct {
+	file  *os.File
+	mutex sync.Mutex
+	dirs  map[int]watchedDir
+	wds   map[string]int
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	return &dirWatcher{
+		// Non-blocking descriptor is managed by the runtime poller, so that
+		// closing the file interrupts the pending read
+		file: os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int]watchedDir),
+		wds:  make(map[string]int)}, nil
+}
+
+// add starts watching the directory. Errors are ignored; the directory is
+// not watched if the limit of the number of watches is reached.
+func (w *dirWatcher) add(dir string, depth int) {
+	wd, err := unix.InotifyAddWatch(int(w.file.Fd()), dir, inotifyMask)
+	if err != nil {
+		return
+	}
+	w.mutex.Lock()
+	w.dirs[wd] = watchedDir{dir, depth}
+	w.wds[dir] = wd
+	w.mutex.Unlock()
+}
+
+// removeTree stops watching the directory and its subdirectories
+func (w *dirWatcher) removeTree(dir string, sep string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for path, wd := range w.wds {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, sep)+sep) {
+			unix.InotifyRmWatch(int(w.file.Fd()), uint32(wd))
+			delete(w.wds, path)
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+func (w *dirWatcher) close() {
+	w.file.Close()
+}
+
+// watch calls the handler with the events read at once until the watcher
+// is closed
+func (w *dirWatcher) watch(handler func([]watchEvent)) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		events := []watchEvent{}
+		w.mutex.Lock()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBegin := offset + unix.SizeofInotifyEvent
+			offset = nameBegin + int(raw.Len)
+			if raw.Mask&unix.IN_IGNORED != 0 {
+				// The directory is removed or no longer watched
+				if dir, found := w.dirs[int(raw.Wd)]; found && w.wds[dir.path] == int(raw.Wd) {
+					delete(w.wds, dir.path)
+				}
+				delete(w.dirs, int(raw.Wd))
+				continue
+			}
+			dir, found := w.dirs[int(raw.Wd)]
+			if !found || raw.Len == 0 || offset > n {
+				continue
+			}
+			events = append(events, watchEvent{
+				dir:      dir.path,
+				name:     strings.TrimRight(string(buf[nameBegin:offset]), "\x00"),
+				depth:    dir.depth + 1,
+				isDir:    raw.Mask&unix.IN_ISDIR != 0,
+				removed:  raw.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0,
+				modified: raw.Mask&(unix.IN_CLOSE_WRITE|unix.IN_ATTRIB) != 0})
+		}
+		w.mutex.Unlock()
+		if len(events) > 0 {
+			handler(events)
+		}
+	}
+}
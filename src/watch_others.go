@@ -0,0 +1,19 @@
+//go:build !linux
+
+package fzf
+
+import "errors"
+
+// --walker-watch is only available on Linux where inotify is supported
+const walkerWatchSupported = false
+
+type dirWatcher struct{}
+
+func newDirWatcher() (*dirWatcher, error) {
+	return nil, errors.New("not supported")
+}
+
+func (w *dirWatcher) add(dir string, depth int)         {}
+func (w *dirWatcher) removeTree(dir string, sep string) {}
+func (w *dirWatcher) close()                            {}
+func (w *dirWatcher) watch(handler func([]watchEvent))  {}
@@ -84,4 +84,164 @@ class TestServer < TestInteractive
       assert_equal 'yo', JSON.parse(res.body, symbolize_names: true)[:query]
     end
   end
+
+  def test_listen_events
+    tmux.send_keys 'seq 10 | fzf --listen 6266 --multi', :Enter
+    tmux.until { |lines| assert_equal 10, lines.match_count }
+
+    events = Queue.new
+    thread = Thread.new do
+      Net::HTTP.start('localhost', 6266) do |http|
+        http.request(Net::HTTP::Get.new('/events')) do |res|
+          res.read_body do |chunk|
+            chunk.each_line { |line| events << JSON.parse(line, symbolize_names: true) }
+          end
+        end
+      end
+    end
+    sleep(0.5)
+
+    uri = URI('http://localhost:6266')
+    Net::HTTP.post(uri, 'change-query(1)')
+    tmux.until { |lines| assert_equal 2, lines.match_count }
+    event = events.pop
+    assert_equal 'change', event[:event]
+    assert_equal '1', event[:query]
+    event = events.pop
+    assert_equal 'result', event[:event]
+    assert_equal 2, event[:matchCount]
+
+    Net::HTTP.post(uri, 'toggle')
+    event = events.pop
+    assert_equal 'multi', event[:event]
+    assert_equal 1, event[:selectedCount]
+
+    Net::HTTP.post(uri, 'up')
+    event = events.pop
+    assert_equal 'focus', event[:event]
+    assert_equal '10', event[:current][:text]
+
+    Net::HTTP.post(uri, 'change-query()+reload(sleep 1; seq 10)')
+    event = events.pop until event[:event] == 'load'
+    refute event[:reading]
+    assert_equal 10, event[:totalCount]
+  ensure
+    thread&.kill
+  end
+
+  def test_listen_json_actions
+    uri = URI('http://localhost:6266')
+    headers = { 'Content-Type' => 'application/json' }
+    tmux.send_keys 'seq 100 | fzf --listen 6266', :Enter
+    tmux.until { |lines| assert_equal 100, lines.match_count }
+
+    body = [{ action: 'change-query', arg: '(1)+' }, { action: 'change-prompt', arg: 'json> ' }].to_json
+    res = Net::HTTP.post(uri, body, headers)
+    assert_equal '200', res.code
+    tmux.until { |lines| assert_equal 'json> (1)+', lines[-1] }
+
+    res = Net::HTTP.post(uri, [{ action: 'clear-query' }, { action: 'bogus' }].to_json, headers)
+    assert_equal '400', res.code
+    assert_equal({ error: 'unknown action: bogus', index: 1 }, JSON.parse(res.body, symbolize_names: true))
+
+    res = Net::HTTP.post(uri, '{"action":', headers)
+    assert_equal '400', res.code
+    assert_match(/^invalid json/, JSON.parse(res.body, symbolize_names: true)[:error])
+  end
+
+  def test_listen_items
+    tmux.send_keys 'seq 3 | fzf --listen 6266 --no-sort', :Enter
+    tmux.until { |lines| assert_equal 3, lines.match_count }
+
+    res = Net::HTTP.post(URI('http://localhost:6266/items'), "foo\nbar\n")
+    assert_equal '200', res.code
+    tmux.until { |lines| assert_equal 5, lines.match_count }
+
+    res = Net::HTTP.post(URI('http://localhost:6266/items?read0=1'), "a\nb\u0000c")
+    assert_equal '200', res.code
+    tmux.until { |lines| assert_equal 7, lines.match_count }
+
+    state = JSON.parse(Net::HTTP.get(URI('http://localhost:6266?fields=matches')), symbolize_names: true)
+    assert_equal %W[1 2 3 foo bar a\nb c], state[:matches].map { |item| item[:text] }
+
+    tmux.send_keys 'o'
+    tmux.until { |lines| assert_equal 2, lines.match_count }
+  end
+
+  def test_listen_post_wait
+    tmux.send_keys 'seq 100000 | fzf --listen 6266', :Enter
+    tmux.until { |lines| assert_equal 100_000, lines.match_count }
+
+    res = Net::HTTP.post(URI('http://localhost:6266?wait=1&limit=1'), 'change-query(12345)')
+    assert_equal '200', res.code
+    state = JSON.parse(res.body, symbolize_names: true)
+    assert_equal '12345', state[:query]
+    assert_equal 1, state[:matchCount]
+    assert_equal '12345', state[:current][:text]
+    assert_equal 1, state[:matches].length
+
+    res = Net::HTTP.post(URI('http://localhost:6266?wait=1'), 'reload(sleep 1; seq 10)+change-query(1)')
+    state = JSON.parse(res.body, symbolize_names: true)
+    refute state[:reading]
+    assert_equal 10, state[:totalCount]
+    assert_equal 2, state[:matchCount]
+  end
+
+  def test_listen_status_fields
+    uri = URI('http://localhost:6266')
+    tmux.send_keys "(echo 'foo bar baz'; echo 'bar foo qux'; seq 10) | fzf --listen 6266 --multi --nth 2.. --query fo", :Enter
+    tmux.until { |lines| assert_equal 1, lines.match_count }
+    Net::HTTP.post(uri, 'toggle')
+    tmux.until { |lines| assert_equal 1, lines.select_count }
+
+    state = JSON.parse(Net::HTTP.get(uri), symbolize_names: true)
+    current = state[:current]
+    assert_equal 'bar foo qux', current[:text]
+    assert_equal [4, 5], current[:positions]
+    assert_equal [[4, 6]], current[:offsets]
+    assert_equal ['foo qux'], current[:nth]
+    assert current[:score].positive?
+    assert current[:selected]
+
+    state = JSON.parse(Net::HTTP.get(URI('http://localhost:6266?fields=current,matchCount')), symbolize_names: true)
+    assert_equal %i[matchCount current], state.keys
+
+    Net::HTTP.post(uri, 'change-query()+select-all')
+    tmux.until { |lines| assert_equal 12, lines.match_count }
+    state = JSON.parse(Net::HTTP.get(URI('http://localhost:6266?matches-limit=2&selected-offset=10')), symbolize_names: true)
+    assert_equal 2, state[:matches].length
+    assert_equal 2, state[:selected].length
+  end
+
+  def test_listen_with_read_only_api_key
+    uri = URI('http://localhost:6266')
+    tmux.send_keys 'seq 10 | FZF_API_KEY=123abc FZF_API_KEY_READONLY=456def fzf --listen 6266', :Enter
+    tmux.until { |lines| assert_equal 10, lines.match_count }
+
+    res = Net::HTTP.get_response(uri, { 'x-api-key' => '456def' })
+    assert_equal '200', res.code
+
+    res = Net::HTTP.post(uri, 'change-query(yo)', { 'x-api-key' => '456def' })
+    assert_equal '403', res.code
+    assert_equal "read-only api key\n", res.body
+
+    res = Net::HTTP.post(uri, 'change-query(yo)', { 'x-api-key' => '123abc' })
+    assert_equal '200', res.code
+    tmux.until { |lines| assert_equal '> yo', lines[-1] }
+  end
+
+  def test_listen_actions
+    uri = URI('http://localhost:6266')
+    tmux.send_keys 'seq 10 | fzf --listen 6266 --listen-actions change-query,toggle-down', :Enter
+    tmux.until { |lines| assert_equal 10, lines.match_count }
+
+    res = Net::HTTP.post(uri, 'change-query(1)+toggle+down')
+    assert_equal '200', res.code
+    tmux.until { |lines| assert_equal 1, lines.select_count }
+
+    res = Net::HTTP.post(uri, 'change-query(2)+execute(touch /tmp/fzf-listen-actions)')
+    assert_equal '403', res.code
+    assert_equal "action not allowed: execute\n", res.body
+    tmux.until { |lines| assert_equal '> 1', lines[-1] }
+  end
 end